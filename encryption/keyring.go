@@ -0,0 +1,202 @@
+// Package encryption seals sensitive values before they are stored. Each value is encrypted
+// with its own data key, and the data key is encrypted ("wrapped") with a key from the
+// keyring, so keys can be rotated by re-wrapping values without changing how they are read.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Prefix starts every sealed value, so sealed and plaintext values can be told apart
+const Prefix = "enc:1:"
+
+var (
+	// ErrNoKey is returned when a value needs sealing or opening and the key isn't configured
+	ErrNoKey = errors.New("encryption key not configured")
+	// ErrMalformed is returned for sealed values that can't be opened
+	ErrMalformed = errors.New("malformed sealed value")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Keyring holds the keys that wrap data keys. New values are sealed with the current key;
+// older keys are kept so values sealed with them can still be opened until they are
+// re-encrypted.
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// ParseKeyring reads a comma separated list of id:key pairs, where each key is 32 bytes of
+// standard base64. The first key is the current one. An empty config gives an empty keyring.
+func ParseKeyring(config string) (*Keyring, error) {
+	keyring := Keyring{keys: map[string]cipher.AEAD{}}
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 || !keyIDPattern.MatchString(parts[0]) {
+			return nil, errors.New("encryption keys must be id:key pairs with ids of letters, digits, - and _")
+		}
+		id := parts[0]
+		if _, ok := keyring.keys[id]; ok {
+			return nil, fmt.Errorf("encryption key %s is listed twice", id)
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("encryption key %s must be 32 bytes of base64", id)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		keyring.keys[id] = aead
+		if keyring.current == "" {
+			keyring.current = id
+		}
+	}
+	return &keyring, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.New("error creating cipher: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.New("error creating cipher: " + err.Error())
+	}
+	return aead, nil
+}
+
+// seal encrypts plaintext with a random nonce, which is kept in front of the ciphertext
+func seal(aead cipher.AEAD, plaintext []byte, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return nil, errors.New("error generating nonce: " + err.Error())
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
+
+// IsSealed reports whether a stored value was sealed
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Seal encrypts a value with a new data key wrapped by the current key
+func (k *Keyring) Seal(value string) (string, error) {
+	if k == nil || k.current == "" {
+		return "", ErrNoKey
+	}
+	dataKey := make([]byte, 32)
+	_, err := io.ReadFull(rand.Reader, dataKey)
+	if err != nil {
+		return "", errors.New("error generating data key: " + err.Error())
+	}
+	wrapped, err := seal(k.keys[k.current], dataKey, []byte(k.current))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(value), nil)
+	if err != nil {
+		return "", err
+	}
+	return Prefix + k.current + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" + base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// Open decrypts a sealed value. Values that aren't sealed are returned as they are.
+func (k *Keyring) Open(value string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrMalformed
+	}
+	if k == nil {
+		return "", ErrNoKey
+	}
+	keyAEAD, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: no key %s", ErrNoKey, parts[0])
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrMalformed
+	}
+	dataKey, err := open(keyAEAD, wrapped, []byte(parts[0]))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(aead, ciphertext, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Empty reports whether the keyring has no key to seal with
+func (k *Keyring) Empty() bool {
+	return k == nil || k.current == ""
+}
+
+// Current reports whether a value is sealed with the current key
+func (k *Keyring) Current(value string) bool {
+	return k != nil && k.current != "" && strings.HasPrefix(value, Prefix+k.current+":")
+}
+
+// Reseal brings a stored value in line with whether it should be sealed: sensitive values are
+// sealed with the current key and other values are stored as plaintext. It reports whether
+// the value changed.
+func (k *Keyring) Reseal(value string, sensitive bool) (string, bool, error) {
+	if value == "" || (sensitive && k.Current(value)) || (!sensitive && !IsSealed(value)) {
+		return value, false, nil
+	}
+	plaintext, err := k.Open(value)
+	if err != nil {
+		return "", false, err
+	}
+	if !sensitive {
+		return plaintext, true, nil
+	}
+	sealed, err := k.Seal(plaintext)
+	if err != nil {
+		return "", false, err
+	}
+	return sealed, true, nil
+}
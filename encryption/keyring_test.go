@@ -0,0 +1,103 @@
+package encryption_test
+
+import (
+	"api/encryption"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	oldKey = "old:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="
+	newKey = "new:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE="
+)
+
+func TestSealAndOpen(t *testing.T) {
+	keyring, err := encryption.ParseKeyring(oldKey)
+	if err != nil {
+		t.Fatal("failed to parse keyring: " + err.Error())
+	}
+	sealed, err := keyring.Seal("123 Main St")
+	if err != nil {
+		t.Fatal("failed to seal: " + err.Error())
+	}
+	if !encryption.IsSealed(sealed) || strings.Contains(sealed, "Main") {
+		t.Error("expected a sealed value; got", sealed)
+	}
+	opened, err := keyring.Open(sealed)
+	if err != nil || opened != "123 Main St" {
+		t.Error("expected the value back; got", opened, err)
+	}
+	opened, err = keyring.Open("plain")
+	if err != nil || opened != "plain" {
+		t.Error("expected plaintext to be returned as it is; got", opened, err)
+	}
+	tampered := sealed[:len(sealed)-2] + "AA"
+	_, err = keyring.Open(tampered)
+	if !errors.Is(err, encryption.ErrMalformed) {
+		t.Error("expected a tampered value to be rejected; got", err)
+	}
+	var empty *encryption.Keyring
+	_, err = empty.Open(sealed)
+	if !errors.Is(err, encryption.ErrNoKey) {
+		t.Error("expected opening without keys to fail; got", err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	old, err := encryption.ParseKeyring(oldKey)
+	if err != nil {
+		t.Fatal("failed to parse keyring: " + err.Error())
+	}
+	sealed, err := old.Seal("license 42")
+	if err != nil {
+		t.Fatal("failed to seal: " + err.Error())
+	}
+	rotated, err := encryption.ParseKeyring(newKey + "," + oldKey)
+	if err != nil {
+		t.Fatal("failed to parse keyring: " + err.Error())
+	}
+	if rotated.Current(sealed) {
+		t.Error("expected the value to be sealed with an old key")
+	}
+	resealed, changed, err := rotated.Reseal(sealed, true)
+	if err != nil || !changed || !rotated.Current(resealed) {
+		t.Fatal("expected the value to be resealed with the new key", err)
+	}
+	_, changed, err = rotated.Reseal(resealed, true)
+	if err != nil || changed {
+		t.Error("expected a current value to be left alone", err)
+	}
+	newOnly, err := encryption.ParseKeyring(newKey)
+	if err != nil {
+		t.Fatal("failed to parse keyring: " + err.Error())
+	}
+	opened, err := newOnly.Open(resealed)
+	if err != nil || opened != "license 42" {
+		t.Error("expected the resealed value to open without the old key; got", opened, err)
+	}
+	plaintext, changed, err := rotated.Reseal(resealed, false)
+	if err != nil || !changed || plaintext != "license 42" {
+		t.Error("expected values that are no longer sensitive to be decrypted; got", plaintext, err)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	for _, config := range []string{"nokey", "a:short", "bad id:" + strings.TrimPrefix(oldKey, "old:"), oldKey + "," + oldKey} {
+		_, err := encryption.ParseKeyring(config)
+		if err == nil {
+			t.Errorf("expected %q to be rejected", config)
+		}
+	}
+	keyring, err := encryption.ParseKeyring("")
+	if err != nil {
+		t.Fatal("failed to parse empty keyring: " + err.Error())
+	}
+	if !keyring.Empty() {
+		t.Error("expected an empty keyring")
+	}
+	_, err = keyring.Seal("x")
+	if !errors.Is(err, encryption.ErrNoKey) {
+		t.Error("expected sealing without keys to fail; got", err)
+	}
+}
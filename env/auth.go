@@ -0,0 +1,117 @@
+package env
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/stytchauth/stytch-go/v3/stytch"
+	"github.com/stytchauth/stytch-go/v3/stytch/stytchapi"
+)
+
+// ErrAuthUserNotFound is returned by an Authenticator when the user does not exist
+var ErrAuthUserNotFound = errors.New("user not found in authenticator")
+
+// Session is an authenticated session issued by an Authenticator
+type Session struct {
+	UserID       string
+	SessionToken string
+	ExpiresAt    time.Time
+}
+
+// Authenticator sends magic links and manages the sessions created from them
+type Authenticator interface {
+	// SendMagicLink emails a login link, creating the user if needed, and returns the user ID
+	SendMagicLink(email string, redirectURL string) (userID string, err error)
+	// AuthenticateToken exchanges a magic link token for a new session
+	AuthenticateToken(token string, sessionDurationMinutes int32) (*Session, error)
+	// AuthenticateSession validates a session token and extends its lifetime
+	AuthenticateSession(sessionToken string, sessionDurationMinutes int32) (*Session, error)
+	RevokeSession(sessionToken string) error
+	DeleteUser(userID string) error
+}
+
+// StytchAuth is an Authenticator backed by the Stytch API
+type StytchAuth struct {
+	client *stytchapi.API
+}
+
+func NewStytchAuth(client *stytchapi.API) *StytchAuth {
+	return &StytchAuth{client: client}
+}
+
+func (s *StytchAuth) SendMagicLink(email string, redirectURL string) (string, error) {
+	resp, err := s.client.MagicLinks.Email.LoginOrCreate(&stytch.MagicLinksEmailLoginOrCreateParams{
+		Email:              email,
+		LoginMagicLinkURL:  redirectURL,
+		SignupMagicLinkURL: redirectURL,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.UserID, nil
+}
+
+func (s *StytchAuth) AuthenticateToken(token string, sessionDurationMinutes int32) (*Session, error) {
+	resp, err := s.client.MagicLinks.Authenticate(&stytch.MagicLinksAuthenticateParams{
+		Token:                  token,
+		SessionDurationMinutes: sessionDurationMinutes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newStytchSession(resp.UserID, resp.SessionToken, resp.Session.ExpiresAt)
+}
+
+func (s *StytchAuth) AuthenticateSession(sessionToken string, sessionDurationMinutes int32) (*Session, error) {
+	resp, err := s.client.Sessions.Authenticate(&stytch.SessionsAuthenticateParams{
+		SessionToken:           sessionToken,
+		SessionDurationMinutes: sessionDurationMinutes,
+	})
+	if err != nil {
+		return nil, err
+	}
+	token := resp.SessionToken
+	if token == "" {
+		token = sessionToken
+	}
+	return newStytchSession(resp.Session.UserID, token, resp.Session.ExpiresAt)
+}
+
+func (s *StytchAuth) RevokeSession(sessionToken string) error {
+	_, err := s.client.Sessions.Revoke(&stytch.SessionsRevokeParams{
+		SessionToken: sessionToken,
+	})
+	return err
+}
+
+func (s *StytchAuth) DeleteUser(userID string) error {
+	_, err := s.client.Users.Delete(userID)
+	if err != nil && strings.Contains(err.Error(), "status code: 404") {
+		return ErrAuthUserNotFound
+	}
+	return err
+}
+
+func newStytchSession(userID string, sessionToken string, expiresAt string) (*Session, error) {
+	session := &Session{
+		UserID:       userID,
+		SessionToken: sessionToken,
+	}
+	if expiresAt != "" {
+		expires, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, errors.New("failed to parse session expiration: " + err.Error())
+		}
+		session.ExpiresAt = expires
+	}
+	return session, nil
+}
+
+func (env Env) initAuth() Authenticator {
+	if os.Getenv("AUTHENTICATOR") == "fake" && env.Name != EnvProd {
+		return NewFakeAuth()
+	}
+	return NewStytchAuth(env.initStytch())
+}
@@ -1,7 +1,9 @@
 package env
 
 import (
+	"api/encryption"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -27,12 +29,17 @@ type SqlConnection struct {
 }
 
 type Env struct {
-	Name   envName
-	DB     *sql.DB
-	Stytch *stytchapi.API
-	Router *gin.Engine
+	Name     envName
+	DB       *sql.DB
+	Auth     Authenticator
+	Sessions *SessionCache
+	Router   *gin.Engine
+	DraftTTL time.Duration       // how long unsubmitted form drafts are kept after they were last saved
+	Keys     *encryption.Keyring // seals answers to sensitive elements
 }
 
+const defaultDraftTTL = 30 * 24 * time.Hour
+
 type envName string
 
 const (
@@ -117,7 +124,23 @@ func Connect(name envName) (*Env, error) {
 	db.SetMaxIdleConns(10)
 	env.DB = db
 
-	env.Stytch = env.initStytch()
+	env.Auth = env.initAuth()
+	env.Sessions, err = env.initSessions()
+	if err != nil {
+		return nil, err
+	}
+	env.DraftTTL, err = initDraftTTL()
+	if err != nil {
+		return nil, err
+	}
+	env.Keys, err = encryption.ParseKeyring(os.Getenv("ENCRYPTION_KEYS"))
+	if err != nil {
+		return nil, err
+	}
+	if env.Keys.Empty() && env.Name != EnvTest {
+		// sensitive answers and Tally responses can't be stored without a key
+		return nil, errors.New("ENCRYPTION_KEYS must be set")
+	}
 	if env.Name != EnvTest {
 		env.Router = gin.Default()
 	}
@@ -125,6 +148,19 @@ func Connect(name envName) (*Env, error) {
 	return &env, nil
 }
 
+// initDraftTTL reads how long form drafts are kept from DRAFT_TTL, which defaults to 30 days
+func initDraftTTL() (time.Duration, error) {
+	ttl := defaultDraftTTL
+	if s := os.Getenv("DRAFT_TTL"); s != "" {
+		var err error
+		ttl, err = time.ParseDuration(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return ttl, nil
+}
+
 func (env Env) initStytch() *stytchapi.API {
 	stytchProjectID := os.Getenv("STYTCH_PROJECT_ID")
 	stytchSecret := os.Getenv("STYTCH_SECRET")
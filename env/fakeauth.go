@@ -0,0 +1,122 @@
+package env
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FakeAuth is an in-process Authenticator for running the API offline.
+// Magic link tokens, session tokens and user IDs are derived from the email
+// address, so tests can predict them without reading an inbox.
+type FakeAuth struct {
+	mu       sync.Mutex
+	emails   map[string]string // user ID -> email
+	tokens   map[string]string // magic link token -> user ID
+	sessions map[string]*Session
+}
+
+func NewFakeAuth() *FakeAuth {
+	return &FakeAuth{
+		emails:   map[string]string{},
+		tokens:   map[string]string{},
+		sessions: map[string]*Session{},
+	}
+}
+
+func fakeID(prefix string, email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(email)))
+	return prefix + hex.EncodeToString(sum[:12])
+}
+
+// FakeUserID returns the user ID the fake authenticator assigns to an email
+func FakeUserID(email string) string {
+	return fakeID("user-test-", email)
+}
+
+// FakeMagicLinkToken returns the magic link token the fake authenticator sends to an email
+func FakeMagicLinkToken(email string) string {
+	return fakeID("token-", email)
+}
+
+// FakeSessionToken returns the session token the fake authenticator issues for an email
+func FakeSessionToken(email string) string {
+	return fakeID("session-", email)
+}
+
+func (f *FakeAuth) SendMagicLink(email string, redirectURL string) (string, error) {
+	if email == "" {
+		return "", errors.New("email is required")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID := FakeUserID(email)
+	f.emails[userID] = email
+	f.tokens[FakeMagicLinkToken(email)] = userID
+	return userID, nil
+}
+
+// AuthenticateToken exchanges a token from SendMagicLink for a session. Like
+// the Stytch sandbox, tokens can be used more than once.
+func (f *FakeAuth) AuthenticateToken(token string, sessionDurationMinutes int32) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID, ok := f.tokens[token]
+	if !ok {
+		return nil, errors.New("magic link token not found")
+	}
+	session := &Session{
+		UserID:       userID,
+		SessionToken: FakeSessionToken(f.emails[userID]),
+		ExpiresAt:    time.Now().Add(time.Duration(sessionDurationMinutes) * time.Minute),
+	}
+	f.sessions[session.SessionToken] = session
+	copied := *session
+	return &copied, nil
+}
+
+func (f *FakeAuth) AuthenticateSession(sessionToken string, sessionDurationMinutes int32) (*Session, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	session, ok := f.sessions[sessionToken]
+	if !ok {
+		return nil, errors.New("session not found")
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(f.sessions, sessionToken)
+		return nil, errors.New("session expired")
+	}
+	session.ExpiresAt = time.Now().Add(time.Duration(sessionDurationMinutes) * time.Minute)
+	copied := *session
+	return &copied, nil
+}
+
+func (f *FakeAuth) RevokeSession(sessionToken string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.sessions[sessionToken]; !ok {
+		return errors.New("session not found")
+	}
+	delete(f.sessions, sessionToken)
+	return nil
+}
+
+func (f *FakeAuth) DeleteUser(userID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	email, ok := f.emails[userID]
+	if !ok {
+		return ErrAuthUserNotFound
+	}
+	delete(f.emails, userID)
+	delete(f.tokens, FakeMagicLinkToken(email))
+	for token, session := range f.sessions {
+		if session.UserID == userID {
+			delete(f.sessions, token)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package env_test
+
+import (
+	"api/env"
+	"testing"
+)
+
+func TestFakeAuthSession(t *testing.T) {
+	auth := env.NewFakeAuth()
+	email := "provider@example.com"
+	userID, err := auth.SendMagicLink(email, "http://localhost:8080/localauth")
+	if err != nil {
+		t.Error("failed to send magic link. " + err.Error())
+		return
+	}
+	if userID != env.FakeUserID(email) {
+		t.Error("expected user ID", env.FakeUserID(email), "; got", userID)
+	}
+
+	session, err := auth.AuthenticateToken(env.FakeMagicLinkToken(email), 60)
+	if err != nil {
+		t.Error("failed to authenticate token. " + err.Error())
+		return
+	}
+	if session.SessionToken != env.FakeSessionToken(email) {
+		t.Error("expected session token", env.FakeSessionToken(email), "; got", session.SessionToken)
+	}
+	if session.UserID != userID {
+		t.Error("expected session user ID", userID, "; got", session.UserID)
+	}
+
+	session, err = auth.AuthenticateSession(session.SessionToken, 60)
+	if err != nil {
+		t.Error("failed to authenticate session. " + err.Error())
+		return
+	}
+	if session.ExpiresAt.IsZero() {
+		t.Error("expected session expiration to be set")
+	}
+
+	err = auth.RevokeSession(session.SessionToken)
+	if err != nil {
+		t.Error("failed to revoke session. " + err.Error())
+	}
+	_, err = auth.AuthenticateSession(session.SessionToken, 60)
+	if err == nil {
+		t.Error("expected error authenticating a revoked session")
+	}
+}
+
+func TestFakeAuthInvalidToken(t *testing.T) {
+	auth := env.NewFakeAuth()
+	_, err := auth.AuthenticateToken(env.FakeMagicLinkToken("nobody@example.com"), 60)
+	if err == nil {
+		t.Error("expected error authenticating a token that was never sent")
+	}
+}
+
+func TestFakeAuthDeleteUser(t *testing.T) {
+	auth := env.NewFakeAuth()
+	email := "deleted@example.com"
+	userID, err := auth.SendMagicLink(email, "")
+	if err != nil {
+		t.Error("failed to send magic link. " + err.Error())
+		return
+	}
+	session, err := auth.AuthenticateToken(env.FakeMagicLinkToken(email), 60)
+	if err != nil {
+		t.Error("failed to authenticate token. " + err.Error())
+		return
+	}
+	err = auth.DeleteUser(userID)
+	if err != nil {
+		t.Error("failed to delete user. " + err.Error())
+	}
+	_, err = auth.AuthenticateSession(session.SessionToken, 60)
+	if err == nil {
+		t.Error("expected error authenticating a deleted user's session")
+	}
+	err = auth.DeleteUser(userID)
+	if err != env.ErrAuthUserNotFound {
+		t.Error("expected ErrAuthUserNotFound deleting a missing user; got", err)
+	}
+}
@@ -0,0 +1,163 @@
+package env
+
+import (
+	"container/list"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSessionCacheSize = 1000
+	defaultSessionCacheTTL  = 5 * time.Minute
+)
+
+// CachedSession is a verified session and the user it resolved to
+type CachedSession struct {
+	Session    Session
+	UserID     int64
+	User       interface{}
+	LoadedAt   time.Time
+	VerifiedAt time.Time // when the session was last checked with the authenticator
+}
+
+// SessionCache is a bounded LRU cache of verified sessions keyed by session token.
+// User data older than the TTL, or belonging to an invalidated user, is reported
+// as stale so it can be reloaded from the database without re-verifying the session.
+type SessionCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type sessionEntry struct {
+	token  string
+	cached CachedSession
+}
+
+func NewSessionCache(size int, ttl time.Duration) *SessionCache {
+	return &SessionCache{
+		size:    size,
+		ttl:     ttl,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Get returns the cached session for a token. ok is false when the token is not
+// cached or its session has expired.
+func (c *SessionCache) Get(token string) (cached CachedSession, stale bool, ok bool) {
+	if c == nil {
+		return cached, false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[token]
+	if !ok {
+		return cached, false, false
+	}
+	entry := elem.Value.(*sessionEntry)
+	if time.Now().After(entry.cached.Session.ExpiresAt) {
+		c.remove(elem)
+		return cached, false, false
+	}
+	c.order.MoveToFront(elem)
+	stale = entry.cached.User == nil || time.Since(entry.cached.LoadedAt) > c.ttl
+	return entry.cached, stale, true
+}
+
+// Set caches a verified session, evicting the least recently used entry when full
+func (c *SessionCache) Set(token string, cached CachedSession) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cached.LoadedAt = time.Now()
+	if elem, ok := c.entries[token]; ok {
+		elem.Value.(*sessionEntry).cached = cached
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[token] = c.order.PushFront(&sessionEntry{token: token, cached: cached})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Delete removes a session token from the cache
+func (c *SessionCache) Delete(token string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[token]; ok {
+		c.remove(elem)
+	}
+}
+
+// InvalidateUser marks every cached session for a user as stale
+func (c *SessionCache) InvalidateUser(userID int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, elem := range c.entries {
+		entry := elem.Value.(*sessionEntry)
+		if entry.cached.UserID == userID {
+			entry.cached.User = nil
+		}
+	}
+}
+
+// InvalidateAll marks every cached session as stale
+func (c *SessionCache) InvalidateAll() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, elem := range c.entries {
+		elem.Value.(*sessionEntry).cached.User = nil
+	}
+}
+
+func (c *SessionCache) Len() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *SessionCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*sessionEntry).token)
+}
+
+// initSessions configures the session cache from SESSION_CACHE_SIZE and SESSION_CACHE_TTL
+func (env Env) initSessions() (*SessionCache, error) {
+	size := defaultSessionCacheSize
+	if s := os.Getenv("SESSION_CACHE_SIZE"); s != "" {
+		var err error
+		size, err = strconv.Atoi(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ttl := defaultSessionCacheTTL
+	if s := os.Getenv("SESSION_CACHE_TTL"); s != "" {
+		var err error
+		ttl, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewSessionCache(size, ttl), nil
+}
@@ -0,0 +1,96 @@
+package env_test
+
+import (
+	"api/env"
+	"testing"
+	"time"
+)
+
+func cachedSession(userID int64, expiresIn time.Duration) env.CachedSession {
+	return env.CachedSession{
+		Session: env.Session{
+			UserID:    "user-test",
+			ExpiresAt: time.Now().Add(expiresIn),
+		},
+		UserID: userID,
+		User:   userID,
+	}
+}
+
+func TestSessionCacheGet(t *testing.T) {
+	cache := env.NewSessionCache(10, time.Minute)
+	cache.Set("token", cachedSession(1, time.Hour))
+	cached, stale, ok := cache.Get("token")
+	if !ok {
+		t.Error("expected cached session")
+		return
+	}
+	if stale {
+		t.Error("expected cached session to be fresh")
+	}
+	if cached.UserID != 1 {
+		t.Error("expected user ID 1; got", cached.UserID)
+	}
+	_, _, ok = cache.Get("missing")
+	if ok {
+		t.Error("expected missing token to not be cached")
+	}
+}
+
+func TestSessionCacheEviction(t *testing.T) {
+	cache := env.NewSessionCache(2, time.Minute)
+	cache.Set("first", cachedSession(1, time.Hour))
+	cache.Set("second", cachedSession(2, time.Hour))
+	// use the first token so the second is least recently used
+	cache.Get("first")
+	cache.Set("third", cachedSession(3, time.Hour))
+	if cache.Len() != 2 {
+		t.Error("expected cache to hold 2 sessions; got", cache.Len())
+	}
+	if _, _, ok := cache.Get("second"); ok {
+		t.Error("expected least recently used session to be evicted")
+	}
+	if _, _, ok := cache.Get("first"); !ok {
+		t.Error("expected recently used session to be kept")
+	}
+}
+
+func TestSessionCacheExpiry(t *testing.T) {
+	cache := env.NewSessionCache(10, time.Minute)
+	cache.Set("expired", cachedSession(1, -time.Second))
+	if _, _, ok := cache.Get("expired"); ok {
+		t.Error("expected expired session to not be returned")
+	}
+	if cache.Len() != 0 {
+		t.Error("expected expired session to be removed")
+	}
+
+	cache = env.NewSessionCache(10, 0)
+	cache.Set("old", cachedSession(1, time.Hour))
+	time.Sleep(time.Millisecond)
+	_, stale, ok := cache.Get("old")
+	if !ok || !stale {
+		t.Error("expected session older than the TTL to be stale")
+	}
+}
+
+func TestSessionCacheInvalidate(t *testing.T) {
+	cache := env.NewSessionCache(10, time.Minute)
+	cache.Set("first", cachedSession(1, time.Hour))
+	cache.Set("second", cachedSession(2, time.Hour))
+	cache.InvalidateUser(1)
+	if _, stale, _ := cache.Get("first"); !stale {
+		t.Error("expected invalidated user to be stale")
+	}
+	if _, stale, _ := cache.Get("second"); stale {
+		t.Error("expected other users to stay fresh")
+	}
+	cache.InvalidateAll()
+	if _, stale, _ := cache.Get("second"); !stale {
+		t.Error("expected all users to be stale")
+	}
+	cache.Delete("second")
+	if _, _, ok := cache.Get("second"); ok {
+		t.Error("expected deleted session to be removed")
+	}
+}
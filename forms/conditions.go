@@ -0,0 +1,223 @@
+package forms
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Condition operators
+const (
+	OpEquals   = "equals"    // the answer is Value, or OptionID is the option chosen
+	OpIncludes = "includes"  // a text answer contains Value, or OptionID is one of the options chosen
+	OpNotEmpty = "not_empty" // the element is answered
+)
+
+const (
+	MatchAll = "all"
+	MatchAny = "any"
+)
+
+// Condition tests the answer to an earlier element in the form. Elements and options are
+// referred to by origin ID so conditions keep working in later versions of the form.
+type Condition struct {
+	ElementID int64  `json:"element_id"`
+	Operator  string `json:"operator"`
+	Value     string `json:"value,omitempty"`     // for elements answered with a value
+	OptionID  int64  `json:"option_id,omitempty"` // for select elements
+}
+
+// ConditionGroup holds when all (the default) or any of its conditions and nested groups hold
+type ConditionGroup struct {
+	Match      string            `json:"match,omitempty"`
+	Conditions []*Condition      `json:"conditions,omitempty"`
+	Groups     []*ConditionGroup `json:"groups,omitempty"`
+}
+
+// GivenAnswer is an answer that conditions are evaluated against
+type GivenAnswer struct {
+	Value     string
+	OptionIDs []int64
+}
+
+// validate checks the group against the elements before the one it belongs to, keyed by
+// origin ID. Values are replaced with their normalized form so they compare with answers.
+func (g *ConditionGroup) validate(earlier map[int64]*Element) error {
+	if g.Match != "" && g.Match != MatchAll && g.Match != MatchAny {
+		return fmt.Errorf("match must be %s or %s", MatchAll, MatchAny)
+	}
+	if len(g.Conditions) == 0 && len(g.Groups) == 0 {
+		return errors.New("condition groups can't be empty")
+	}
+	for _, condition := range g.Conditions {
+		err := condition.validate(earlier)
+		if err != nil {
+			return err
+		}
+	}
+	for _, group := range g.Groups {
+		err := group.validate(earlier)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Condition) validate(earlier map[int64]*Element) error {
+	element, ok := earlier[c.ElementID]
+	if !ok {
+		return fmt.Errorf("condition refers to element %v, which must be a saved element earlier in the form", c.ElementID)
+	}
+	switch c.Operator {
+	case OpNotEmpty:
+		if c.Value != "" || c.OptionID != 0 {
+			return errors.New("not_empty conditions don't take a value or option")
+		}
+		return nil
+	case OpEquals:
+		if element.Type == TypeMultiSelect {
+			return errors.New("use includes for conditions on multi_select elements")
+		}
+	case OpIncludes:
+		if !element.IsSelect() && !textTypes[element.Type] {
+			return fmt.Errorf("includes conditions don't apply to %s elements", element.Type)
+		}
+	default:
+		return fmt.Errorf("unknown condition operator %q", c.Operator)
+	}
+
+	if element.IsSelect() {
+		if c.Value != "" {
+			return fmt.Errorf("conditions on element %v must use option_id instead of value", c.ElementID)
+		}
+		for _, option := range element.Options {
+			if option.OriginID != 0 && option.OriginID == c.OptionID && !option.Archived {
+				return nil
+			}
+		}
+		return fmt.Errorf("option %v is not a saved option of element %v", c.OptionID, c.ElementID)
+	}
+	if c.OptionID != 0 {
+		return fmt.Errorf("element %v doesn't have options", c.ElementID)
+	}
+	value := strings.TrimSpace(c.Value)
+	if value == "" {
+		return fmt.Errorf("%s conditions need a value", c.Operator)
+	}
+	if c.Operator == OpEquals {
+		normalized, err := elementTypes[element.Type].normalize(value)
+		if err != nil {
+			return fmt.Errorf("condition value %q is not a valid answer to element %v. %s", c.Value, c.ElementID, err.Error())
+		}
+		value = normalized
+	}
+	c.Value = value
+	return nil
+}
+
+// holds reports whether the group holds for answers keyed by origin ID
+func (g *ConditionGroup) holds(answers map[int64]*GivenAnswer, elements map[int64]*Element) bool {
+	// any stops at the first condition that holds, all at the first that doesn't
+	matchAny := g.Match == MatchAny
+	for _, condition := range g.Conditions {
+		if condition.holds(answers[condition.ElementID], elements[condition.ElementID]) == matchAny {
+			return matchAny
+		}
+	}
+	for _, group := range g.Groups {
+		if group.holds(answers, elements) == matchAny {
+			return matchAny
+		}
+	}
+	return !matchAny
+}
+
+func (c *Condition) holds(answer *GivenAnswer, element *Element) bool {
+	if answer == nil || element == nil {
+		return false
+	}
+	if c.Operator == OpNotEmpty {
+		return strings.TrimSpace(answer.Value) != "" || len(answer.OptionIDs) > 0
+	}
+	if element.IsSelect() {
+		for _, optionID := range answer.OptionIDs {
+			for _, option := range element.Options {
+				if option.ID == optionID && option.OriginID == c.OptionID {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	if c.Operator == OpIncludes {
+		return strings.Contains(strings.ToLower(answer.Value), strings.ToLower(c.Value))
+	}
+	return answer.Value == c.Value
+}
+
+// VisibleElements works out which of the form's elements are shown for a set of normalized
+// answers keyed by element ID. Elements without conditions are always shown. Answers to
+// hidden elements are ignored, so hiding an element also hides anything that depends on it.
+func (f *Form) VisibleElements(answers map[int64]*GivenAnswer) map[int64]bool {
+	elements := append([]*Element{}, f.Elements...)
+	sort.SliceStable(elements, func(i, j int) bool {
+		return elements[i].Position < elements[j].Position
+	})
+	visible := map[int64]bool{}
+	shown := map[int64]*GivenAnswer{}
+	byOrigin := map[int64]*Element{}
+	for _, element := range elements {
+		visible[element.ID] = element.ShowIf == nil || element.ShowIf.holds(shown, byOrigin)
+		byOrigin[element.OriginID] = element
+		if visible[element.ID] {
+			shown[element.OriginID] = answers[element.ID]
+		}
+	}
+	return visible
+}
+
+// validateConditions checks that conditions only refer to saved, live elements and options
+// earlier in the form. Elements must already be in position order.
+func validateConditions(elements []*Element) error {
+	earlier := map[int64]*Element{}
+	for _, element := range elements {
+		if element.ShowIf != nil {
+			err := element.ShowIf.validate(earlier)
+			if err != nil {
+				return fmt.Errorf("%w: element %q has invalid conditions. %s", ErrInvalidForm, element.Label, err.Error())
+			}
+		}
+		if element.OriginID != 0 && !element.Archived {
+			earlier[element.OriginID] = element
+		}
+	}
+	return nil
+}
+
+// conditionsValue stores conditions as JSON, or null when there are none
+func conditionsValue(group *ConditionGroup) (interface{}, error) {
+	if group == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(group)
+	if err != nil {
+		return nil, errors.New("failed to encode conditions: " + err.Error())
+	}
+	return string(data), nil
+}
+
+func parseConditions(data sql.NullString) (*ConditionGroup, error) {
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+	var group ConditionGroup
+	err := json.Unmarshal([]byte(data.String), &group)
+	if err != nil {
+		return nil, errors.New("failed to decode conditions: " + err.Error())
+	}
+	return &group, nil
+}
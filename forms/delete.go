@@ -0,0 +1,90 @@
+package forms
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// FormDeletion reports what DeleteForm did
+type FormDeletion struct {
+	Responses int64 `json:"responses"` // responses to any version of the form
+	Purged    bool  `json:"purged"`    // false if the form was only hidden to keep its responses
+}
+
+// lockForm makes sure a form exists and hasn't been deleted, and locks it for the rest of the transaction
+func lockForm(id int64, tx *sql.Tx) error {
+	var found int64
+	err := tx.QueryRow("SELECT id FROM forms WHERE id = ? AND deletedAt IS NULL FOR UPDATE", id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("%w: form %v", ErrFormNotFound, id)
+	}
+	if err != nil {
+		return errors.New("failed to get form: " + err.Error())
+	}
+	return nil
+}
+
+// DeleteForm deletes a form along with its versions, elements and options. A form that has
+// been answered is only marked deleted so its responses keep their questions, unless purge
+// is set, in which case the responses are deleted too. Everything happens in one transaction.
+func DeleteForm(id int64, purge bool, db *sql.DB) (*FormDeletion, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("failed to start transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	err = lockForm(id, tx)
+	if err != nil {
+		return nil, err
+	}
+	// drafts can't be resumed or submitted once the form is gone
+	_, err = tx.Exec("DELETE FROM response_drafts WHERE formID = ?", id)
+	if err != nil {
+		return nil, errors.New("failed to delete drafts: " + err.Error())
+	}
+	var deletion FormDeletion
+	err = tx.QueryRow("SELECT count(*) FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?)", id).Scan(&deletion.Responses)
+	if err != nil {
+		return nil, errors.New("failed to count responses: " + err.Error())
+	}
+
+	if deletion.Responses > 0 && !purge {
+		_, err = tx.Exec("UPDATE forms SET deletedAt = ?, live = false WHERE id = ?", time.Now(), id)
+		if err != nil {
+			return nil, errors.New("failed to mark form deleted: " + err.Error())
+		}
+	} else {
+		deletion.Purged = true
+		// children first, so nothing is left pointing at a deleted row if this fails partway
+		deletes := []struct {
+			query string
+			name  string
+		}{
+			{"DELETE FROM response_options WHERE responseID IN (SELECT id FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?))", "response options"},
+			{"DELETE FROM response_revisions WHERE responseID IN (SELECT id FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?))", "response revisions"},
+			{"DELETE FROM response_decisions WHERE responseID IN (SELECT id FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?))", "response decisions"},
+			{"DELETE FROM response_comments WHERE responseID IN (SELECT id FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?))", "response comments"},
+			{"DELETE FROM provider_attributes WHERE responseID IN (SELECT id FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?))", "provider attributes"},
+			{"DELETE FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?)", "responses"},
+			{"DELETE FROM options WHERE elementID IN (SELECT id FROM elements WHERE formID = ?)", "options"},
+			{"DELETE FROM elements WHERE formID = ?", "elements"},
+			{"DELETE FROM sections WHERE formID = ?", "sections"},
+			{"DELETE FROM section_progress WHERE formID = ?", "section progress"},
+			{"DELETE FROM form_versions WHERE formID = ?", "form versions"},
+			{"DELETE FROM forms WHERE id = ?", "form"},
+		}
+		for _, d := range deletes {
+			_, err = tx.Exec(d.query, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to delete %s: %s", d.name, err.Error())
+			}
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("failed to commit form deletion: " + err.Error())
+	}
+	return &deletion, nil
+}
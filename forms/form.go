@@ -6,41 +6,112 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Querier is satisfied by both *sql.DB and *sql.Tx so the same queries can run inside a transaction
+type Querier interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// ErrFormNotFound is returned for forms that don't exist, have been deleted, or aren't live or
+// published
+var ErrFormNotFound = errors.New("form not found")
+
 type Form struct {
-	ID       int64      `json:"id"`
-	Name     string     `json:"name"`
-	Required bool       `json:"required"`
-	Live     bool       `json:"live"`
-	Elements []*Element `json:"elements"`
+	ID          int64      `json:"id"`
+	Name        string     `json:"name"`
+	Required    bool       `json:"required"`
+	Live        bool       `json:"live"`
+	Version     int        `json:"version"`
+	PublishedAt *time.Time `json:"published_at"` // nil while the version is a draft
+	Elements    []*Element `json:"elements"`
+	// Sections nest the same elements by page. When an update has sections other than the
+	// implicit one, elements are taken from them and top level elements are ignored.
+	Sections []*Section `json:"sections"`
+	// DeleteAnswered deletes answered elements and options left out of an update instead of archiving them
+	DeleteAnswered bool `json:"delete_answered,omitempty"`
 }
 
 type Element struct {
-	ID       int64     `json:"id"`
-	FormID   int64     `json:"form_id"`
-	Label    string    `json:"label"`
-	Type     string    `json:"type"`
-	Position int       `json:"position"` // index
-	Required bool      `json:"required"`
-	Priority int       `json:"priority"`
-	Search   bool      `json:"search"`
-	Options  []*Option `json:"options"`
+	ID         int64           `json:"id"`
+	FormID     int64           `json:"form_id"`
+	VersionID  int64           `json:"-"`
+	OriginID   int64           `json:"origin_id"` // the same element in every version of the form
+	Label      string          `json:"label"`
+	Type       string          `json:"type"`
+	Position   int             `json:"position"` // index
+	Required   bool            `json:"required"`
+	Priority   int             `json:"priority"`
+	Search     bool            `json:"search"`
+	Visibility string          `json:"visibility"` // who can see approved answers on public outputs
+	Sensitive  bool            `json:"sensitive"`  // answers are encrypted and never shown on public outputs
+	SectionID  int64           `json:"section_id"` // 0 if the element isn't in a section
+	Rules      *Rules          `json:"rules,omitempty"`
+	ShowIf     *ConditionGroup `json:"show_if,omitempty"` // the element is hidden unless these hold
+	Archived   bool            `json:"archived"`          // removed from the form but kept because it has answers
+	Options    []*Option       `json:"options"`
+	section    *Section        // the section an update puts the element in, before it has an ID
 }
 
 type Option struct {
 	ID        int64  `json:"id"`
 	ElementID int64  `json:"element_id"`
+	OriginID  int64  `json:"origin_id"` // the same option in every version of the form
 	Name      string `json:"name"`
 	Position  int    `json:"position"` // index
+	Archived  bool   `json:"archived"`
+}
+
+const elementColumns = "id, formID, versionID, originID, label, type, position, required, priority, search, visibility, sensitive, sectionID, rules, showIf, archived"
+
+const optionColumns = "id, elementID, originID, name, position, archived"
+
+func scanOption(row scanner) (*Option, error) {
+	var option Option
+	var originID sql.NullInt64
+	err := row.Scan(&option.ID, &option.ElementID, &originID, &option.Name, &option.Position, &option.Archived)
+	if err != nil {
+		return nil, err
+	}
+	option.OriginID = originID.Int64
+	return &option, nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanElement(row scanner) (*Element, error) {
+	var element Element
+	var versionID, originID, sectionID sql.NullInt64
+	var rules, showIf sql.NullString
+	err := row.Scan(&element.ID, &element.FormID, &versionID, &originID, &element.Label, &element.Type, &element.Position, &element.Required, &element.Priority, &element.Search, &element.Visibility, &element.Sensitive, &sectionID, &rules, &showIf, &element.Archived)
+	if err != nil {
+		return nil, err
+	}
+	element.VersionID = versionID.Int64
+	element.OriginID = originID.Int64
+	element.SectionID = sectionID.Int64
+	element.Rules, err = parseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	element.ShowIf, err = parseConditions(showIf)
+	if err != nil {
+		return nil, err
+	}
+	return &element, nil
 }
 
 func GetForms(db *sql.DB) ([]Form, error) {
 	forms := []Form{}
 
-	selectForms := "SELECT id, name, required, live FROM forms"
+	selectForms := "SELECT id, name, required, live FROM forms WHERE deletedAt IS NULL"
 	rows, err := db.Query(selectForms)
 	if err != nil {
 		fmt.Println("Failed SQL: " + selectForms)
@@ -60,7 +131,7 @@ func GetForms(db *sql.DB) ([]Form, error) {
 }
 
 func GetLiveForms(db *sql.DB) ([]*Form, error) {
-	selectForms := "select id, name, required, live from forms where live = true"
+	selectForms := "select id, name, required, live from forms where live = true and currentVersionID is not null and deletedAt is null"
 	rows, err := db.Query(selectForms)
 	if err != nil {
 		return nil, errors.New("failed to get forms: " + err.Error())
@@ -78,46 +149,143 @@ func GetLiveForms(db *sql.DB) ([]*Form, error) {
 	return forms, nil
 }
 
+// GetForm returns a form with its elements. With onlyLive, the form must be live and the
+// current published version is returned. Otherwise the latest version is returned, which is
+// the draft if the form is being edited.
 func GetForm(id int64, onlyLive bool, db *sql.DB) (*Form, error) {
 	var form Form
-	selectForm := "SELECT id, name, required, live FROM forms WHERE id = ?"
+	var currentVersionID sql.NullInt64
+	selectForm := "SELECT id, name, required, live, currentVersionID FROM forms WHERE id = ? AND deletedAt IS NULL"
 	if onlyLive {
 		selectForm += " AND live = true"
 	}
-	err := db.QueryRow(selectForm, id).Scan(&form.ID, &form.Name, &form.Required, &form.Live)
+	err := db.QueryRow(selectForm, id).Scan(&form.ID, &form.Name, &form.Required, &form.Live, &currentVersionID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: form %v", ErrFormNotFound, id)
+	}
 	if err != nil {
 		return nil, errors.New("failed to get form: " + err.Error())
 	}
-	selectElements := "SELECT id, formID, label, type, position, required, priority, search FROM elements WHERE formID = ?"
-	rows, err := db.Query(selectElements, form.ID)
+	versionID := currentVersionID.Int64
+	if onlyLive && !currentVersionID.Valid {
+		return nil, fmt.Errorf("%w: form %v has not been published", ErrFormNotFound, id)
+	}
+	if !onlyLive {
+		err := db.QueryRow("SELECT id FROM form_versions WHERE formID = ? ORDER BY version DESC LIMIT 1", id).Scan(&versionID)
+		if err != nil {
+			return nil, errors.New("failed to get form version: " + err.Error())
+		}
+	}
+	err = loadVersion(&form, versionID, db)
+	if err != nil {
+		return nil, err
+	}
+	if onlyLive {
+		form.Elements = withoutArchived(form.Elements)
+		nestElements(&form)
+	}
+	return &form, nil
+}
+
+// withoutArchived drops archived elements and options, for showing a form to people answering it
+func withoutArchived(elements []*Element) []*Element {
+	shown := []*Element{}
+	for _, element := range elements {
+		if element.Archived {
+			continue
+		}
+		var options []*Option
+		for _, option := range element.Options {
+			if !option.Archived {
+				options = append(options, option)
+			}
+		}
+		element.Options = options
+		shown = append(shown, element)
+	}
+	return shown
+}
+
+// loadVersion fills in a form's version details and elements
+func loadVersion(form *Form, versionID int64, db Querier) error {
+	var publishedAt sql.NullTime
+	err := db.QueryRow("SELECT version, publishedAt FROM form_versions WHERE id = ?", versionID).Scan(&form.Version, &publishedAt)
+	if err != nil {
+		return errors.New("failed to get form version: " + err.Error())
+	}
+	form.PublishedAt = nil
+	if publishedAt.Valid {
+		form.PublishedAt = &publishedAt.Time
+	}
+	form.Elements, err = getVersionElements(versionID, db)
+	if err != nil {
+		return err
+	}
+	form.Sections, err = getVersionSections(versionID, db)
+	if err != nil {
+		return err
+	}
+	nestElements(form)
+	return nil
+}
+
+// getVersionElements returns the elements of a form version in position order, with their options
+func getVersionElements(versionID int64, db Querier) ([]*Element, error) {
+	rows, err := db.Query("SELECT "+elementColumns+" FROM elements WHERE versionID = ? ORDER BY position, id", versionID)
 	if err != nil {
 		return nil, errors.New("failed to get elements: " + err.Error())
 	}
 	defer rows.Close()
+	var elements []*Element
+	byID := map[int64]*Element{}
 	for rows.Next() {
-		var element Element
-		err := rows.Scan(&element.ID, &element.FormID, &element.Label, &element.Type, &element.Position, &element.Required, &element.Priority, &element.Search)
+		element, err := scanElement(rows)
 		if err != nil {
 			return nil, errors.New("failed to scan element: " + err.Error())
 		}
-		selectOptions := "SELECT id, elementID, name, position FROM options WHERE elementID = ?"
-		optionRows, err := db.Query(selectOptions, element.ID)
+		elements = append(elements, element)
+		byID[element.ID] = element
+	}
+	if len(elements) == 0 {
+		return elements, nil
+	}
+
+	selectOptions := "SELECT " + optionColumns + " FROM options WHERE elementID IN (SELECT id FROM elements WHERE versionID = ?) ORDER BY position, id"
+	optionRows, err := db.Query(selectOptions, versionID)
+	if err != nil {
+		return nil, errors.New("failed to get options: " + err.Error())
+	}
+	defer optionRows.Close()
+	for optionRows.Next() {
+		option, err := scanOption(optionRows)
 		if err != nil {
-			return nil, errors.New("failed to get options: " + err.Error())
-		}
-		defer optionRows.Close()
-		for optionRows.Next() {
-			var option Option
-			err := optionRows.Scan(&option.ID, &option.ElementID, &option.Name, &option.Position)
-			if err != nil {
-				return nil, errors.New("failed to scan option: " + err.Error())
-			}
-			element.Options = append(element.Options, &option)
+			return nil, errors.New("failed to scan option: " + err.Error())
 		}
-		form.Elements = append(form.Elements, &element)
+		element := byID[option.ElementID]
+		element.Options = append(element.Options, option)
 	}
+	return elements, nil
+}
 
-	return &form, nil
+// GetElement returns an element with its options
+func GetElement(id int64, db *sql.DB) (*Element, error) {
+	element, err := scanElement(db.QueryRow("SELECT "+elementColumns+" FROM elements WHERE id = ?", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get element %v: %s", id, err.Error())
+	}
+	rows, err := db.Query("SELECT "+optionColumns+" FROM options WHERE elementID = ? ORDER BY position, id", id)
+	if err != nil {
+		return nil, errors.New("failed to get options: " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		option, err := scanOption(rows)
+		if err != nil {
+			return nil, errors.New("failed to scan option: " + err.Error())
+		}
+		element.Options = append(element.Options, option)
+	}
+	return element, nil
 }
 
 func GetFormHandler(c *gin.Context, onlyLive bool, db *sql.DB) {
@@ -129,6 +297,12 @@ func GetFormHandler(c *gin.Context, onlyLive bool, db *sql.DB) {
 		return
 	}
 	form, err := GetForm(id, onlyLive, db)
+	if errors.Is(err, ErrFormNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -138,8 +312,30 @@ func GetFormHandler(c *gin.Context, onlyLive bool, db *sql.DB) {
 	c.JSON(http.StatusOK, gin.H{"form": form})
 }
 
+// NewForm creates a form and its first version, which is published right away if the form is live
 func NewForm(form *Form, db *sql.DB) (*Form, error) {
-	resp, err := db.Exec("INSERT INTO forms (name, required, live) VALUES (?, ?, ?)", form.Name, form.Required, form.Live)
+	err := flattenSections(form)
+	if err != nil {
+		return nil, err
+	}
+	err = validateElements(form.Elements)
+	if err != nil {
+		return nil, err
+	}
+	// nothing is saved yet, so conditions have nothing to refer to
+	for _, element := range form.Elements {
+		element.OriginID = 0
+	}
+	err = validateConditions(form.Elements)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("failed to start transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	resp, err := tx.Exec("INSERT INTO forms (name, required, live) VALUES (?, ?, ?)", form.Name, form.Required, form.Live)
 	if err != nil {
 		return nil, errors.New("failed to insert form: " + err.Error())
 	}
@@ -148,21 +344,66 @@ func NewForm(form *Form, db *sql.DB) (*Form, error) {
 		return nil, errors.New("failed to get inserted form id: " + err.Error())
 	}
 	form.ID = id
+	form.Version = 1
+	form.PublishedAt = nil
+	if form.Live {
+		now := time.Now()
+		form.PublishedAt = &now
+	}
+	versionID, err := insertVersion(id, form.Version, form.PublishedAt, tx)
+	if err != nil {
+		return nil, err
+	}
+	if form.Live {
+		_, err = tx.Exec("UPDATE forms SET currentVersionID = ? WHERE id = ?", versionID, id)
+		if err != nil {
+			return nil, errors.New("failed to set current form version: " + err.Error())
+		}
+	}
+	for _, section := range form.Sections {
+		section.FormID = id
+		section.VersionID = versionID
+		section.OriginID = 0
+		_, err := NewSection(section, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
 	var elems []*Element
 	for _, element := range form.Elements {
 		element.FormID = id
-		elem, err := NewElement(element, db)
+		element.VersionID = versionID
+		element.SectionID = 0
+		if element.section != nil {
+			element.SectionID = element.section.ID
+		}
+		elem, err := NewElement(element, tx)
 		if err != nil {
 			return nil, errors.New("failed to insert element: " + err.Error())
 		}
 		elems = append(elems, elem)
 	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("failed to commit form: " + err.Error())
+	}
 	form.Elements = elems
+	nestElements(form)
 	return form, nil
 }
 
-func NewElement(element *Element, db *sql.DB) (*Element, error) {
-	resp, err := db.Exec("INSERT INTO elements (formID, label, type, position, required, priority, search) VALUES (?, ?, ?, ?, ?, ?, ?)", element.FormID, element.Label, element.Type, element.Position, element.Required, element.Priority, element.Search)
+// NewElement inserts an element into element.VersionID. New elements are their own origin;
+// copies of an element into a later version keep the origin they were copied from.
+func NewElement(element *Element, db Querier) (*Element, error) {
+	rules, err := rulesValue(element.Rules)
+	if err != nil {
+		return nil, err
+	}
+	showIf, err := conditionsValue(element.ShowIf)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := db.Exec("INSERT INTO elements (formID, versionID, label, type, position, required, priority, search, visibility, sensitive, sectionID, rules, showIf, archived) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", element.FormID, element.VersionID, element.Label, element.Type, element.Position, element.Required, element.Priority, element.Search, element.Visibility, element.Sensitive, sql.NullInt64{Int64: element.SectionID, Valid: element.SectionID != 0}, rules, showIf, element.Archived)
 	if err != nil {
 		return nil, errors.New("failed to insert element: " + err.Error())
 	}
@@ -171,6 +412,13 @@ func NewElement(element *Element, db *sql.DB) (*Element, error) {
 		return nil, errors.New("failed to get inserted element id: " + err.Error())
 	}
 	element.ID = id
+	if element.OriginID == 0 {
+		element.OriginID = id
+	}
+	_, err = db.Exec("UPDATE elements SET originID = ? WHERE id = ?", element.OriginID, id)
+	if err != nil {
+		return nil, errors.New("failed to set element origin: " + err.Error())
+	}
 	for i, option := range element.Options {
 		option.ElementID = id
 		option, err := NewOption(option, db)
@@ -182,8 +430,10 @@ func NewElement(element *Element, db *sql.DB) (*Element, error) {
 	return element, nil
 }
 
-func NewOption(option *Option, db *sql.DB) (*Option, error) {
-	resp, err := db.Exec("INSERT INTO options (elementID, name, position) VALUES (?, ?, ?)", option.ElementID, option.Name, option.Position)
+// NewOption inserts an option into option.ElementID. Like elements, new options are their
+// own origin and copies keep the origin they were copied from.
+func NewOption(option *Option, db Querier) (*Option, error) {
+	resp, err := db.Exec("INSERT INTO options (elementID, name, position, archived) VALUES (?, ?, ?, ?)", option.ElementID, option.Name, option.Position, option.Archived)
 	if err != nil {
 		return nil, errors.New("failed to insert option: " + err.Error())
 	}
@@ -192,33 +442,26 @@ func NewOption(option *Option, db *sql.DB) (*Option, error) {
 		return nil, errors.New("failed to get inserted option id: " + err.Error())
 	}
 	option.ID = id
+	if option.OriginID == 0 {
+		option.OriginID = id
+	}
+	_, err = db.Exec("UPDATE options SET originID = ? WHERE id = ?", option.OriginID, id)
+	if err != nil {
+		return nil, errors.New("failed to set option origin: " + err.Error())
+	}
 	return option, nil
 }
 
-func UpdateForm(form *Form, db *sql.DB) error {
-	_, err := db.Exec("UPDATE forms SET name = ?, required = ?, live = ? WHERE id = ?", form.Name, form.Required, form.Live, form.ID)
+func UpdateElement(element *Element, db Querier) error {
+	rules, err := rulesValue(element.Rules)
 	if err != nil {
-		return errors.New("failed to update form: " + err.Error())
+		return err
 	}
-	for _, element := range form.Elements {
-		if element.ID > 0 {
-			err := UpdateElement(element, db)
-			if err != nil {
-				return errors.New("failed to update element: " + err.Error())
-			}
-		} else {
-			element.FormID = form.ID
-			_, err := NewElement(element, db)
-			if err != nil {
-				return errors.New("failed to create element: " + err.Error())
-			}
-		}
+	showIf, err := conditionsValue(element.ShowIf)
+	if err != nil {
+		return err
 	}
-	return nil
-}
-
-func UpdateElement(element *Element, db *sql.DB) error {
-	_, err := db.Exec("UPDATE elements SET label = ?, type = ?, position = ?, required = ?, priority = ?, search = ? WHERE id = ?", element.Label, element.Type, element.Position, element.Required, element.Priority, element.Search, element.ID)
+	_, err = db.Exec("UPDATE elements SET label = ?, type = ?, position = ?, required = ?, priority = ?, search = ?, visibility = ?, sensitive = ?, sectionID = ?, rules = ?, showIf = ?, archived = ? WHERE id = ?", element.Label, element.Type, element.Position, element.Required, element.Priority, element.Search, element.Visibility, element.Sensitive, sql.NullInt64{Int64: element.SectionID, Valid: element.SectionID != 0}, rules, showIf, element.Archived, element.ID)
 	if err != nil {
 		return errors.New("failed to update element: " + err.Error())
 	}
@@ -239,26 +482,10 @@ func UpdateElement(element *Element, db *sql.DB) error {
 	return nil
 }
 
-func UpdateOption(option *Option, db *sql.DB) error {
-	_, err := db.Exec("UPDATE options SET name = ?, position = ? WHERE id = ?", option.Name, option.Position, option.ID)
+func UpdateOption(option *Option, db Querier) error {
+	_, err := db.Exec("UPDATE options SET name = ?, position = ?, archived = ? WHERE id = ?", option.Name, option.Position, option.Archived, option.ID)
 	if err != nil {
 		return errors.New("failed to update option: " + err.Error())
 	}
 	return nil
 }
-
-func DeleteForm(id int64, db *sql.DB) error {
-	_, err := db.Exec("DELETE FROM forms WHERE id = ?", id)
-	if err != nil {
-		return errors.New("failed to delete form: " + err.Error())
-	}
-	_, err = db.Exec("DELETE FROM elements WHERE formID = ?", id)
-	if err != nil {
-		return errors.New("failed to delete elements: " + err.Error())
-	}
-	_, err = db.Exec("DELETE FROM options WHERE elementID IN (SELECT id FROM elements WHERE formID = ?)", id)
-	if err != nil {
-		return errors.New("failed to delete options: " + err.Error())
-	}
-	return nil
-}
@@ -3,6 +3,7 @@ package forms_test
 import (
 	"api/env"
 	"api/forms"
+	"errors"
 	"strings"
 	"testing"
 )
@@ -43,7 +44,7 @@ func TestNewForm(t *testing.T) {
 	e := env.TestSetup(t, true, pathToDotEnv)
 	element := forms.Element{
 		Label: "Test Element",
-		Type:  "text",
+		Type:  forms.TypeShortText,
 	}
 	newForm := forms.Form{
 		Name:     "Test Form",
@@ -62,6 +63,14 @@ func TestNewForm(t *testing.T) {
 	if form.Name == "" {
 		t.Error("form name is empty")
 	}
+
+	_, err = forms.NewForm(&forms.Form{
+		Name:     "Invalid Form",
+		Elements: []*forms.Element{{Label: "Unknown", Type: "signature"}},
+	}, e.DB)
+	if !errors.Is(err, forms.ErrInvalidForm) {
+		t.Error("expected error creating form with an unknown element type")
+	}
 }
 
 func TestUpdateForm(t *testing.T) {
@@ -80,12 +89,12 @@ func TestUpdateForm(t *testing.T) {
 	form.Elements = []*forms.Element{
 		{
 			Label:    "New element for updated form",
-			Type:     "text",
+			Type:     forms.TypeShortText,
 			Position: 0,
 			Required: false,
 		},
 	}
-	err = forms.UpdateForm(form, e.DB)
+	_, err = forms.UpdateForm(form, e.DB)
 	if err != nil {
 		t.Error("error updating form. " + err.Error())
 		return
@@ -115,15 +124,18 @@ func TestUpdateForm(t *testing.T) {
 	}
 
 	// delete the form
-	err = forms.DeleteForm(form.ID, e.DB)
+	deletion, err := forms.DeleteForm(form.ID, false, e.DB)
 	if err != nil {
 		t.Error("error deleting form. " + err.Error())
 		return
 	}
+	if !deletion.Purged || deletion.Responses != 0 {
+		t.Error("expected a form without responses to be removed; got", deletion)
+	}
 	// validate the form is gone
 	deletedForm, err := forms.GetForm(form.ID, false, e.DB)
 	if err != nil {
-		if strings.Contains(err.Error(), "no rows in result set") {
+		if errors.Is(err, forms.ErrFormNotFound) {
 			t.Log("form deleted successfully")
 		} else {
 			t.Error("error getting deleted form. " + err.Error())
@@ -134,3 +146,274 @@ func TestUpdateForm(t *testing.T) {
 		t.Error("form still exists")
 	}
 }
+
+func TestFormVersions(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	form, err := forms.NewForm(&forms.Form{
+		Name: "Versioned Form",
+		Live: true,
+		Elements: []*forms.Element{
+			{Label: "Do you accept Medicaid?", Type: forms.TypeBoolean},
+		},
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating form. " + err.Error())
+		return
+	}
+	defer forms.DeleteForm(form.ID, true, e.DB)
+	if form.Version != 1 || form.PublishedAt == nil {
+		t.Error("expected a live form to start with published version 1")
+	}
+
+	form.Elements[0].Label = "Do you accept Medicare?"
+	_, err = forms.UpdateForm(form, e.DB)
+	if err != nil {
+		t.Error("error updating form. " + err.Error())
+		return
+	}
+	current, err := forms.GetForm(form.ID, true, e.DB)
+	if err != nil {
+		t.Error("error getting current form. " + err.Error())
+		return
+	}
+	if current.Version != 1 || current.Elements[0].Label != "Do you accept Medicaid?" {
+		t.Error("expected the published version to be unchanged until the draft is published")
+	}
+	draft, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting draft. " + err.Error())
+		return
+	}
+	if draft.Version != 2 || draft.PublishedAt != nil || draft.Elements[0].Label != "Do you accept Medicare?" {
+		t.Error("expected edits to be saved to draft version 2")
+	}
+	if draft.Elements[0].OriginID != current.Elements[0].OriginID {
+		t.Error("expected the draft element to keep the origin of the published element")
+	}
+	answered, err := forms.GetCurrentVersion(form.ID, e.DB)
+	if err != nil {
+		t.Error("error getting current version. " + err.Error())
+		return
+	}
+	if answered.Version != 1 {
+		t.Error("expected the current version to be the published one while a draft exists; got", answered.Version)
+	}
+
+	published, err := forms.PublishForm(form.ID, e.DB)
+	if err != nil {
+		t.Error("error publishing form. " + err.Error())
+		return
+	}
+	if published.Version != 2 || published.PublishedAt == nil {
+		t.Error("expected version 2 to be published")
+	}
+	_, err = forms.PublishForm(form.ID, e.DB)
+	if !errors.Is(err, forms.ErrInvalidForm) {
+		t.Error("expected error publishing a form without a draft")
+	}
+	first, err := forms.GetFormVersion(form.ID, 1, e.DB)
+	if err != nil {
+		t.Error("error getting version 1. " + err.Error())
+		return
+	}
+	if first.Elements[0].Label != "Do you accept Medicaid?" {
+		t.Error("expected version 1 to keep its original wording; got", first.Elements[0].Label)
+	}
+	_, err = forms.GetFormVersion(form.ID, 3, e.DB)
+	if !errors.Is(err, forms.ErrVersionNotFound) {
+		t.Error("expected version 3 to not be found")
+	}
+}
+
+func TestUnpublishedLiveForm(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	form, err := forms.NewForm(&forms.Form{
+		Name: "Unpublished Form",
+		Elements: []*forms.Element{
+			{Label: "Do you accept Medicaid?", Type: forms.TypeBoolean},
+		},
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating form. " + err.Error())
+		return
+	}
+	defer forms.DeleteForm(form.ID, true, e.DB)
+
+	form.Live = true
+	_, err = forms.UpdateForm(form, e.DB)
+	if err != nil {
+		t.Error("error updating form. " + err.Error())
+		return
+	}
+	_, err = forms.GetForm(form.ID, true, e.DB)
+	if !errors.Is(err, forms.ErrFormNotFound) {
+		t.Error("expected a live form without a published version to not be found; got", err)
+	}
+}
+
+func TestUpdateFormDiff(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	form, err := forms.NewForm(&forms.Form{
+		Name: "Form to reorder",
+		Elements: []*forms.Element{
+			{Label: "First", Type: forms.TypeShortText, Position: 0},
+			{Label: "Second", Type: forms.TypeShortText, Position: 1},
+			{Label: "Third", Type: forms.TypeSingleSelect, Position: 2, Options: []*forms.Option{
+				{Name: "A", Position: 0},
+				{Name: "B", Position: 1},
+			}},
+		},
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating form. " + err.Error())
+		return
+	}
+	defer forms.DeleteForm(form.ID, true, e.DB)
+	first, second, third := form.Elements[0], form.Elements[1], form.Elements[2]
+
+	// drop the second element and option A, move the third to the top and add a new element
+	third.Position = 0
+	third.Options = third.Options[1:]
+	first.Position = 5
+	form.Elements = []*forms.Element{
+		first,
+		third,
+		{Label: "Fourth", Type: forms.TypeLongText, Position: 9},
+	}
+	diff, err := forms.UpdateForm(form, e.DB)
+	if err != nil {
+		t.Error("error updating form. " + err.Error())
+		return
+	}
+	if len(diff.Added) != 1 || len(diff.Deleted) != 1 || diff.Deleted[0] != second.ID {
+		t.Error("expected one element added and the second deleted; got", diff)
+	}
+	updated, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting updated form. " + err.Error())
+		return
+	}
+	labels := []string{}
+	for i, element := range updated.Elements {
+		if element.Position != i {
+			t.Error("expected contiguous positions; got", element.Position, "at", i)
+		}
+		labels = append(labels, element.Label)
+	}
+	if strings.Join(labels, ",") != "Third,First,Fourth" {
+		t.Error("expected elements Third,First,Fourth; got", labels)
+	}
+	if len(updated.Elements[0].Options) != 1 || updated.Elements[0].Options[0].Name != "B" || updated.Elements[0].Options[0].Position != 0 {
+		t.Error("expected only option B at position 0")
+	}
+
+	updated.Elements = append(updated.Elements, &forms.Element{ID: second.ID, Label: "Deleted", Type: forms.TypeShortText})
+	_, err = forms.UpdateForm(updated, e.DB)
+	if !errors.Is(err, forms.ErrInvalidForm) {
+		t.Error("expected error updating an element that is not in the draft")
+	}
+}
+
+func TestFormSections(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	form, err := forms.NewForm(&forms.Form{
+		Name: "Form with pages",
+		Sections: []*forms.Section{
+			{Title: "About you", Position: 0, Elements: []*forms.Element{
+				{Label: "Name", Type: forms.TypeShortText, Position: 0},
+			}},
+			{Title: "Your practice", Position: 1, Elements: []*forms.Element{
+				{Label: "Practice name", Type: forms.TypeShortText, Position: 0},
+				{Label: "Website", Type: forms.TypeURL, Position: 1},
+			}},
+		},
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating form. " + err.Error())
+		return
+	}
+	defer forms.DeleteForm(form.ID, true, e.DB)
+	saved, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting form. " + err.Error())
+		return
+	}
+	if len(saved.Sections) != 2 || len(saved.Sections[0].Elements) != 1 || len(saved.Sections[1].Elements) != 2 {
+		t.Error("expected elements to be nested under their sections; got", saved.Sections)
+		return
+	}
+	if saved.Sections[1].Elements[0].Position != 1 || len(saved.Elements) != 3 {
+		t.Error("expected elements to keep their place in the whole form")
+	}
+
+	// move the website to the first section and drop the second
+	saved.Sections = saved.Sections[:1]
+	saved.Sections[0].Elements = append(saved.Sections[0].Elements, form.Elements[2])
+	_, err = forms.UpdateForm(saved, e.DB)
+	if err != nil {
+		t.Error("error updating form. " + err.Error())
+		return
+	}
+	updated, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting updated form. " + err.Error())
+		return
+	}
+	if len(updated.Sections) != 1 || len(updated.Sections[0].Elements) != 2 || updated.Sections[0].Elements[1].Label != "Website" {
+		t.Error("expected one section with the name and website; got", updated.Sections)
+	}
+
+	// a flat update removes the sections
+	updated.Sections = nil
+	_, err = forms.UpdateForm(updated, e.DB)
+	if err != nil {
+		t.Error("error updating form. " + err.Error())
+		return
+	}
+	flat, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting flat form. " + err.Error())
+		return
+	}
+	if len(flat.Sections) != 1 || !flat.Sections[0].Implicit || len(flat.Sections[0].Elements) != 2 {
+		t.Error("expected a flat form to have one implicit section; got", flat.Sections)
+	}
+}
+
+func TestUpdateFlatFormElements(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	form, err := forms.NewForm(&forms.Form{
+		Name: "Flat form",
+		Elements: []*forms.Element{
+			{Label: "Name", Type: forms.TypeShortText, Position: 0},
+		},
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating form. " + err.Error())
+		return
+	}
+	read, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting form. " + err.Error())
+		return
+	}
+	// the form comes back with its implicit section, which an edit of the elements leaves as it is
+	read.Elements[0].Label = "Full name"
+	read.Elements = append(read.Elements, &forms.Element{Label: "Website", Type: forms.TypeURL, Position: 1})
+	_, err = forms.UpdateForm(read, e.DB)
+	if err != nil {
+		t.Error("error updating form. " + err.Error())
+		return
+	}
+	updated, err := forms.GetForm(form.ID, false, e.DB)
+	if err != nil {
+		t.Error("error getting updated form. " + err.Error())
+		return
+	}
+	if len(updated.Elements) != 2 || updated.Elements[0].Label != "Full name" || updated.Elements[1].Label != "Website" {
+		t.Error("expected the edited elements; got", updated.Elements)
+	}
+	if len(updated.Sections) != 1 || !updated.Sections[0].Implicit {
+		t.Error("expected the form to stay flat; got", updated.Sections)
+	}
+}
@@ -0,0 +1,263 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Drafts hold a user's unfinished answers to a form so they can come back to it. Saving a
+// draft doesn't check that answers are complete or valid; that happens when it is submitted.
+// Answers to sensitive elements are sealed in drafts like they are in responses.
+
+// ErrDraftNotFound is returned when a user has no draft of a form, or it has expired
+var ErrDraftNotFound = errors.New("draft not found")
+
+type Draft struct {
+	FormID      int64     `json:"form_id"`
+	UserID      int64     `json:"user_id"`
+	FormVersion int       `json:"form_version"` // the version the answers were mapped onto
+	Answers     []*Answer `json:"answers"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// draftAnswer is a draft answer stored by element and option origin, so drafts still apply
+// after a new version of the form is published
+type draftAnswer struct {
+	ElementID int64   `json:"element_id"`
+	Value     string  `json:"value,omitempty"`
+	OptionIDs []int64 `json:"option_ids,omitempty"`
+}
+
+// getDraftAnswers returns the stored answers of the user's unexpired draft, keyed by element origin
+func getDraftAnswers(formID int64, userID int64, db forms.Querier) (map[int64]*draftAnswer, *Draft, error) {
+	selectDraft := "select answers, updatedAt, expiresAt from response_drafts where userID = ? and formID = ? and expiresAt > ?"
+	draft := Draft{FormID: formID, UserID: userID}
+	var data string
+	err := db.QueryRow(selectDraft, userID, formID, time.Now()).Scan(&data, &draft.UpdatedAt, &draft.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("%w: user %v has no draft of form %v", ErrDraftNotFound, userID, formID)
+	}
+	if err != nil {
+		return nil, nil, errors.New("error getting draft: " + err.Error())
+	}
+	answers, err := decodeDraftAnswers(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return answers, &draft, nil
+}
+
+// lockDraft creates the user's draft if they don't have one and locks it until tx ends, so
+// saves of the same draft are applied one after another. Answers of an expired draft are
+// left out.
+func lockDraft(formID int64, userID int64, version int, now time.Time, tx *sql.Tx) (map[int64]*draftAnswer, error) {
+	_, err := tx.Exec(
+		"insert ignore into response_drafts (userID, formID, formVersion, answers, createdAt, updatedAt, expiresAt) values (?, ?, ?, '[]', ?, ?, ?)",
+		userID, formID, version, now, now, now,
+	)
+	if err != nil {
+		return nil, errors.New("error creating draft: " + err.Error())
+	}
+	var data string
+	var expiresAt time.Time
+	err = tx.QueryRow("select answers, expiresAt from response_drafts where userID = ? and formID = ? for update", userID, formID).Scan(&data, &expiresAt)
+	if err != nil {
+		return nil, errors.New("error getting draft: " + err.Error())
+	}
+	if !expiresAt.After(now) {
+		return map[int64]*draftAnswer{}, nil
+	}
+	return decodeDraftAnswers(data)
+}
+
+// decodeDraftAnswers decodes stored draft answers, keyed by element origin
+func decodeDraftAnswers(data string) (map[int64]*draftAnswer, error) {
+	var stored []*draftAnswer
+	err := json.Unmarshal([]byte(data), &stored)
+	if err != nil {
+		return nil, errors.New("error decoding draft: " + err.Error())
+	}
+	answers := map[int64]*draftAnswer{}
+	for _, answer := range stored {
+		answers[answer.ElementID] = answer
+	}
+	return answers, nil
+}
+
+// mergeDraftAnswers applies updates to stored answers, both keyed by element origin, and
+// returns them in element origin order. Empty updates remove the element's answer.
+func mergeDraftAnswers(stored map[int64]*draftAnswer, updates map[int64]*draftAnswer) []*draftAnswer {
+	for originID, update := range updates {
+		if update.Value == "" && len(update.OptionIDs) == 0 {
+			delete(stored, originID)
+			continue
+		}
+		stored[originID] = update
+	}
+	list := make([]*draftAnswer, 0, len(stored))
+	for _, answer := range stored {
+		list = append(list, answer)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].ElementID < list[j].ElementID
+	})
+	return list
+}
+
+// toAnswers maps stored draft answers onto the elements and options of the form, dropping
+// answers to elements and options that are no longer on it, and opens sealed values
+func toAnswers(form *forms.Form, stored map[int64]*draftAnswer, keys *encryption.Keyring) ([]*Answer, error) {
+	answers := []*Answer{}
+	for _, element := range form.Elements {
+		saved, ok := stored[element.OriginID]
+		if !ok {
+			continue
+		}
+		value, err := openValue(saved.Value, keys)
+		if err != nil {
+			return nil, err
+		}
+		answer := Answer{ElementID: element.ID, Value: value}
+		for _, originID := range saved.OptionIDs {
+			for _, option := range element.Options {
+				if option.OriginID == originID {
+					answer.OptionIDs = append(answer.OptionIDs, option.ID)
+				}
+			}
+		}
+		if !answerIsEmpty(&answer) {
+			answers = append(answers, &answer)
+		}
+	}
+	return answers, nil
+}
+
+// GetDraft returns the user's draft of a live form, with answers mapped onto its current version
+func GetDraft(formID int64, userID int64, keys *encryption.Keyring, db *sql.DB) (*Draft, error) {
+	form, err := forms.GetForm(formID, true, db)
+	if err != nil {
+		return nil, err
+	}
+	stored, draft, err := getDraftAnswers(formID, userID, db)
+	if err != nil {
+		return nil, err
+	}
+	draft.FormVersion = form.Version
+	draft.Answers, err = toAnswers(form, stored, keys)
+	if err != nil {
+		return nil, err
+	}
+	return draft, nil
+}
+
+// SaveDraft merges answers into the user's draft of a live form, creating it if needed. An
+// empty answer removes the element from the draft. Answers are only checked to belong to the
+// form; required elements and answer formats are checked on submit. The draft expires ttl
+// after it was last saved.
+func SaveDraft(formID int64, userID int64, answers []*Answer, ttl time.Duration, keys *encryption.Keyring, db *sql.DB) (*Draft, error) {
+	form, err := forms.GetForm(formID, true, db)
+	if err != nil {
+		return nil, err
+	}
+	elements := map[int64]*forms.Element{}
+	for _, element := range form.Elements {
+		elements[element.ID] = element
+	}
+	invalid := ValidationErrors{}
+	updates := map[int64]*draftAnswer{}
+	for _, answer := range answers {
+		element, ok := elements[answer.ElementID]
+		if !ok {
+			invalid[answer.ElementID] = "element is not part of this form"
+			continue
+		}
+		if _, ok := updates[element.OriginID]; ok {
+			invalid[answer.ElementID] = "element is answered more than once"
+			continue
+		}
+		options := map[int64]int64{}
+		for _, option := range element.Options {
+			options[option.ID] = option.OriginID
+		}
+		value, err := sealAnswer(element, answer.Value, keys)
+		if err != nil {
+			return nil, err
+		}
+		update := draftAnswer{ElementID: element.OriginID, Value: value}
+		for _, optionID := range answer.OptionIDs {
+			originID, ok := options[optionID]
+			if !ok {
+				invalid[answer.ElementID] = fmt.Sprintf("option %v is not an option for this question", optionID)
+				break
+			}
+			update.OptionIDs = append(update.OptionIDs, originID)
+		}
+		updates[element.OriginID] = &update
+	}
+	if len(invalid) > 0 {
+		return nil, invalid
+	}
+
+	// drafts are only read while they are unexpired, so old ones can go whenever
+	now := time.Now()
+	_, err = db.Exec("delete from response_drafts where expiresAt <= ?", now)
+	if err != nil {
+		return nil, errors.New("error deleting expired drafts: " + err.Error())
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	stored, err := lockDraft(formID, userID, form.Version, now, tx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(mergeDraftAnswers(stored, updates))
+	if err != nil {
+		return nil, errors.New("error encoding draft: " + err.Error())
+	}
+	draft := Draft{FormID: formID, UserID: userID, FormVersion: form.Version, UpdatedAt: now, ExpiresAt: now.Add(ttl)}
+	_, err = tx.Exec(
+		"update response_drafts set formVersion = ?, answers = ?, updatedAt = ?, expiresAt = ? where userID = ? and formID = ?",
+		form.Version, string(data), now, draft.ExpiresAt, userID, formID,
+	)
+	if err != nil {
+		return nil, errors.New("error saving draft: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing draft: " + err.Error())
+	}
+	draft.Answers, err = toAnswers(form, stored, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// SubmitDraft submits the user's draft of a form like SubmitForm, which deletes the draft
+// once its answers are saved. If the draft is invalid it is kept and the error is ValidationErrors.
+func SubmitDraft(formID int64, userID int64, keys *encryption.Keyring, db *sql.DB) ([]*Response, error) {
+	draft, err := GetDraft(formID, userID, keys, db)
+	if err != nil {
+		return nil, err
+	}
+	return SubmitForm(formID, userID, &Submission{Answers: draft.Answers}, keys, db)
+}
+
+// DeleteDraft discards the user's draft of a form
+func DeleteDraft(formID int64, userID int64, db *sql.DB) error {
+	_, err := db.Exec("delete from response_drafts where userID = ? and formID = ?", userID, formID)
+	if err != nil {
+		return errors.New("error deleting draft: " + err.Error())
+	}
+	return nil
+}
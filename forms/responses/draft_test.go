@@ -0,0 +1,76 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"testing"
+)
+
+func TestToAnswers(t *testing.T) {
+	keys, err := encryption.ParseKeyring("test:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	if err != nil {
+		t.Fatal("failed to parse keyring: " + err.Error())
+	}
+	sealed, err := keys.Seal("123 Main St")
+	if err != nil {
+		t.Fatal("failed to seal: " + err.Error())
+	}
+	// version 2 of a form whose elements and options were copied from version 1
+	form := &forms.Form{
+		Elements: []*forms.Element{
+			{ID: 21, OriginID: 1, Type: forms.TypeShortText},
+			{ID: 22, OriginID: 2, Type: forms.TypeMultiSelect, Options: []*forms.Option{{ID: 210, OriginID: 10}, {ID: 211, OriginID: 11}}},
+			{ID: 23, OriginID: 3, Type: forms.TypeShortText, Sensitive: true},
+			{ID: 24, OriginID: 4, Type: forms.TypeSingleSelect, Options: []*forms.Option{{ID: 220, OriginID: 20}}},
+		},
+	}
+	stored := map[int64]*draftAnswer{
+		1: {ElementID: 1, Value: "Sam"},
+		2: {ElementID: 2, OptionIDs: []int64{11, 12}}, // option 12 was removed
+		3: {ElementID: 3, Value: sealed},
+		4: {ElementID: 4, OptionIDs: []int64{21}}, // its only option was removed
+		5: {ElementID: 5, Value: "removed element"},
+	}
+	answers, err := toAnswers(form, stored, keys)
+	if err != nil {
+		t.Fatal("failed to map draft answers: " + err.Error())
+	}
+	if len(answers) != 3 {
+		t.Fatal("expected answers to the three elements still on the form; got", len(answers))
+	}
+	if answers[0].ElementID != 21 || answers[0].Value != "Sam" {
+		t.Error("expected the answer mapped onto the new element; got", answers[0])
+	}
+	if answers[1].ElementID != 22 || len(answers[1].OptionIDs) != 1 || answers[1].OptionIDs[0] != 211 {
+		t.Error("expected only the option still on the form, mapped onto its copy; got", answers[1])
+	}
+	if answers[2].ElementID != 23 || answers[2].Value != "123 Main St" {
+		t.Error("expected the sealed answer opened; got", answers[2])
+	}
+}
+
+func TestMergeDraftAnswers(t *testing.T) {
+	stored := map[int64]*draftAnswer{
+		1: {ElementID: 1, Value: "Sam"},
+		2: {ElementID: 2, OptionIDs: []int64{10}},
+		3: {ElementID: 3, Value: "Denver"},
+	}
+	updates := map[int64]*draftAnswer{
+		1: {ElementID: 1, Value: "Alex"},
+		2: {ElementID: 2},
+		4: {ElementID: 4, Value: "Boulder"},
+	}
+	merged := mergeDraftAnswers(stored, updates)
+	if len(merged) != 3 {
+		t.Fatal("expected the emptied answer to be removed; got", len(merged))
+	}
+	want := []struct {
+		elementID int64
+		value     string
+	}{{1, "Alex"}, {3, "Denver"}, {4, "Boulder"}}
+	for i, w := range want {
+		if merged[i].ElementID != w.elementID || merged[i].Value != w.value {
+			t.Errorf("expected %v in element order; got %+v", w, merged[i])
+		}
+	}
+}
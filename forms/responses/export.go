@@ -0,0 +1,231 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+// ExportFilter chooses the responses and format of an export
+type ExportFilter struct {
+	Format        string    `form:"format"`
+	Approved      *bool     `form:"approved"` // only the latest approved answers when true
+	CreatedAfter  time.Time `form:"created_after"`
+	CreatedBefore time.Time `form:"created_before"`
+}
+
+// Export is a spreadsheet of a form's responses with one row per user and one column per
+// element of the form's current published version. Answers to earlier versions of an element go in its
+// column.
+type Export struct {
+	Format   string
+	form     *forms.Form
+	filter   ResponseFilter
+	keys     *encryption.Keyring
+	db       *sql.DB
+	columns  map[int64]int // by element origin
+	elements []*forms.Element
+}
+
+// PrepareExport checks the filter and loads the form, so errors can be reported before the
+// export starts streaming
+func PrepareExport(formID int64, filter *ExportFilter, keys *encryption.Keyring, db *sql.DB) (*Export, error) {
+	format := filter.Format
+	if format == "" {
+		format = ExportCSV
+	}
+	if format != ExportCSV && format != ExportXLSX {
+		return nil, fmt.Errorf("%w: unknown export format %q", ErrResponseQuery, filter.Format)
+	}
+	export := Export{
+		Format: format,
+		filter: ResponseFilter{
+			FormID:        formID,
+			Approved:      filter.Approved,
+			CreatedAfter:  filter.CreatedAfter,
+			CreatedBefore: filter.CreatedBefore,
+		},
+		keys:    keys,
+		db:      db,
+		columns: map[int64]int{},
+	}
+	_, _, err := export.filter.where()
+	if err != nil {
+		return nil, err
+	}
+	export.form, err = forms.GetCurrentVersion(formID, db)
+	if err != nil {
+		return nil, err
+	}
+	export.elements = export.form.Elements
+	for i, element := range export.elements {
+		export.columns[element.OriginID] = i
+	}
+	return &export, nil
+}
+
+// ContentType is the media type of the export
+func (x *Export) ContentType() string {
+	if x.Format == ExportXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename is a name to save the export as
+func (x *Export) Filename() string {
+	return "form-" + strconv.FormatInt(x.form.ID, 10) + "-responses." + x.Format
+}
+
+// rowWriter writes spreadsheet rows
+type rowWriter interface {
+	WriteRow(cells []string) error
+	Close() error
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteRow(cells []string) error {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = escapeFormula(cell)
+	}
+	return c.w.Write(escaped)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// escapeFormula keeps spreadsheet apps from running answers that look like formulas
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// exportRow holds one user's answers while their responses are read
+type exportRow struct {
+	userID  int64
+	name    string
+	email   string
+	answers []string
+	options map[int][]string // option names by column
+}
+
+func (r *exportRow) cells() []string {
+	cells := append([]string{strconv.FormatInt(r.userID, 10), r.name, r.email}, r.answers...)
+	for column, names := range r.options {
+		cells[column+3] = strings.Join(names, ", ")
+	}
+	return cells
+}
+
+// Write streams the export to w. Rows are written as each user's responses are read, so the
+// whole export is never held in memory.
+func (x *Export) Write(w io.Writer) error {
+	var out rowWriter
+	if x.Format == ExportXLSX {
+		xlsx, err := newXLSXWriter(w)
+		if err != nil {
+			return err
+		}
+		out = xlsx
+	} else {
+		out = &csvWriter{w: csv.NewWriter(w)}
+	}
+	header := []string{"User ID", "Name", "Email"}
+	for _, element := range x.elements {
+		header = append(header, element.Label)
+	}
+	err := out.WriteRow(header)
+	if err != nil {
+		return errors.New("error writing export: " + err.Error())
+	}
+
+	where, args, err := x.filter.where()
+	if err != nil {
+		return err
+	}
+	rows, err := x.db.Query(
+		"select r.id, r.userID, u.firstName, u.lastName, u.email, e.originID, r.value, o.name "+
+			"from (responses r, elements e) left join users u on u.id = r.userID "+
+			"left join response_options ro on ro.responseID = r.id left join options o on o.id = ro.optionID "+
+			"where "+where+" order by r.userID, r.id, o.position, o.id",
+		args...,
+	)
+	if err != nil {
+		return errors.New("error selecting responses to export: " + err.Error())
+	}
+	defer rows.Close()
+	var row *exportRow
+	var lastResponseID int64
+	for rows.Next() {
+		var responseID, userID, originID int64
+		var firstName, lastName, email, value, optionName sql.NullString
+		err := rows.Scan(&responseID, &userID, &firstName, &lastName, &email, &originID, &value, &optionName)
+		if err != nil {
+			return errors.New("error scanning response to export: " + err.Error())
+		}
+		if row == nil || row.userID != userID {
+			if row != nil {
+				err = out.WriteRow(row.cells())
+				if err != nil {
+					return errors.New("error writing export: " + err.Error())
+				}
+			}
+			row = &exportRow{
+				userID:  userID,
+				name:    strings.TrimSpace(firstName.String + " " + lastName.String),
+				email:   email.String,
+				answers: make([]string, len(x.elements)),
+				options: map[int][]string{},
+			}
+		}
+		column, ok := x.columns[originID]
+		if !ok {
+			continue
+		}
+		if optionName.Valid {
+			row.options[column] = append(row.options[column], optionName.String)
+		}
+		if responseID != lastResponseID && value.Valid {
+			row.answers[column], err = openValue(value.String, x.keys)
+			if err != nil {
+				return err
+			}
+		}
+		lastResponseID = responseID
+	}
+	err = rows.Err()
+	if err != nil {
+		return errors.New("error selecting responses to export: " + err.Error())
+	}
+	if row != nil {
+		err = out.WriteRow(row.cells())
+		if err != nil {
+			return errors.New("error writing export: " + err.Error())
+		}
+	}
+	err = out.Close()
+	if err != nil {
+		return errors.New("error writing export: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package responses
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExportRowCells(t *testing.T) {
+	row := exportRow{userID: 7, name: "Ada Lovelace", email: "ada@example.com", answers: []string{"Denver", ""}, options: map[int][]string{1: {"Spanish", "English"}}}
+	cells := strings.Join(row.cells(), "|")
+	if cells != "7|Ada Lovelace|ada@example.com|Denver|Spanish, English" {
+		t.Error("expected option names joined in their column; got", cells)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	out := &csvWriter{w: csv.NewWriter(&buf)}
+	err := out.WriteRow([]string{"=HYPERLINK(\"x\")", "a, b"})
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		t.Fatal("failed to write csv: " + err.Error())
+	}
+	if buf.String() != "\"'=HYPERLINK(\"\"x\"\")\",\"a, b\"\n" {
+		t.Error("expected formulas to be escaped; got", buf.String())
+	}
+}
+
+func TestXLSXWriter(t *testing.T) {
+	var buf bytes.Buffer
+	out, err := newXLSXWriter(&buf)
+	if err != nil {
+		t.Fatal("failed to create workbook: " + err.Error())
+	}
+	err = out.WriteRow([]string{"Name", "<b>&"})
+	if err == nil {
+		err = out.Close()
+	}
+	if err != nil {
+		t.Fatal("failed to write workbook: " + err.Error())
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal("failed to read workbook: " + err.Error())
+	}
+	var sheet string
+	for _, f := range archive.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal("failed to open worksheet: " + err.Error())
+		}
+		data, _ := io.ReadAll(r)
+		sheet = string(data)
+	}
+	if !strings.Contains(sheet, "<t xml:space=\"preserve\">&lt;b&gt;&amp;</t>") || !strings.HasSuffix(sheet, "</sheetData></worksheet>") {
+		t.Error("expected escaped cells in the worksheet; got", sheet)
+	}
+	if len(archive.File) != len(xlsxParts)+1 {
+		t.Error("expected every part of the workbook; got", len(archive.File))
+	}
+}
+
+func TestPrepareExportFormat(t *testing.T) {
+	_, err := PrepareExport(1, &ExportFilter{Format: "pdf"}, nil, nil)
+	if !errors.Is(err, ErrResponseQuery) {
+		t.Error("expected unknown formats to be rejected; got", err)
+	}
+}
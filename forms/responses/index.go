@@ -0,0 +1,82 @@
+package responses
+
+import (
+	"api/encryption"
+	"database/sql"
+	"errors"
+)
+
+// Approved answers to elements marked Search are copied into provider_attributes so the
+// provider directory can filter on them. Only a provider's latest approved response to an
+// element is indexed. Select elements are indexed by option name, one row per option.
+// Attributes are keyed by the element's origin so they carry across form versions, and
+// whether an element is searchable comes from the form's current version. Sealed values are
+// never indexed.
+
+const latestApproved = "r.approved = true " +
+	"and r.id = (select max(r2.id) from responses r2, elements e2 where r2.elementID = e2.id and e2.originID = e.originID and r2.userID = r.userID and r2.approved = true) " +
+	"and exists (select 1 from elements ce, forms cf where ce.formID = cf.id and ce.versionID = cf.currentVersionID and cf.deletedAt is null and ce.originID = e.originID and ce.search = true)"
+
+// reindex replaces the attributes matching deleteScope, a condition on provider_attributes,
+// with those built from the responses matching insertScope, a condition on responses r and
+// elements e
+func reindex(deleteScope string, insertScope string, args []interface{}, db *sql.DB) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	err = reindexTx(deleteScope, insertScope, args, tx)
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("error committing provider attributes: " + err.Error())
+	}
+	return nil
+}
+
+// reindexTx is reindex inside a transaction the caller commits
+func reindexTx(deleteScope string, insertScope string, args []interface{}, tx *sql.Tx) error {
+	_, err := tx.Exec("delete from provider_attributes where "+deleteScope, args...)
+	if err != nil {
+		return errors.New("error deleting provider attributes: " + err.Error())
+	}
+	_, err = tx.Exec("insert into provider_attributes (userID, elementID, responseID, value) select r.userID, e.originID, r.id, r.value from responses r, elements e where r.elementID = e.id and r.value is not null and r.value <> '' and r.value not like '"+encryption.Prefix+"%' and "+latestApproved+" and "+insertScope, args...)
+	if err != nil {
+		return errors.New("error indexing response values: " + err.Error())
+	}
+	_, err = tx.Exec("insert into provider_attributes (userID, elementID, responseID, optionID, value) select r.userID, e.originID, r.id, o.id, o.name from responses r, elements e, response_options ro, options o where r.elementID = e.id and ro.responseID = r.id and o.id = ro.optionID and "+latestApproved+" and "+insertScope, args...)
+	if err != nil {
+		return errors.New("error indexing response options: " + err.Error())
+	}
+	return nil
+}
+
+// ReindexResponse rebuilds the attributes for the provider and element a response belongs to
+func ReindexResponse(id int64, db *sql.DB) error {
+	var userID, originID int64
+	err := db.QueryRow("select r.userID, e.originID from responses r, elements e where r.elementID = e.id and r.id = ?", id).Scan(&userID, &originID)
+	if err != nil {
+		return errors.New("error selecting response: " + err.Error())
+	}
+	return reindex("userID = ? and elementID = ?", "r.userID = ? and e.originID = ?", []interface{}{userID, originID}, db)
+}
+
+// reindexResponses rebuilds the attributes for the providers and elements of a set of responses
+// inside tx
+func reindexResponses(ids []int64, tx *sql.Tx) error {
+	placeholders, args := idPlaceholders(ids)
+	return reindexTx(
+		"(userID, elementID) in (select sr.userID, se.originID from responses sr, elements se where sr.elementID = se.id and sr.id in ("+placeholders+"))",
+		"exists (select 1 from responses sr, elements se where sr.elementID = se.id and sr.id in ("+placeholders+") and sr.userID = r.userID and se.originID = e.originID)",
+		args, tx,
+	)
+}
+
+// ReindexForm rebuilds the attributes for every element in a form, for when a new version
+// marks or unmarks elements as Search or renames options
+func ReindexForm(formID int64, db *sql.DB) error {
+	return reindex("elementID in (select originID from elements where formID = ?)", "e.formID = ?", []interface{}{formID}, db)
+}
@@ -0,0 +1,235 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultResponseLimit = 50
+	maxResponseLimit     = 200
+)
+
+// ErrResponseQuery is wrapped by errors for invalid response filters
+var ErrResponseQuery = errors.New("invalid response query")
+
+// ResponseFilter narrows and pages a response listing. Zero values don't filter.
+type ResponseFilter struct {
+	UserID        int64     `form:"user_id"`
+	FormID        int64     `form:"form_id"`
+	ElementID     int64     `form:"element_id"` // matches the element in every version of its form
+	Approved      *bool     `form:"approved"`   // only the latest approved answers when true
+	Reviewed      *bool     `form:"reviewed"`   // whether an admin has approved or rejected the response
+	ReviewStatus  string    `form:"review_status"`
+	CreatedAfter  time.Time `form:"created_after"`
+	CreatedBefore time.Time `form:"created_before"`
+	Cursor        string    `form:"cursor"`
+	Limit         int       `form:"limit"`
+	// Audience limits responses to elements visible to it (see forms.VisibleTo). Empty for
+	// admins and users listing their own responses, who see everything.
+	Audience string `form:"-"`
+}
+
+// ResponsePage is one page of responses in the order they were submitted
+type ResponsePage struct {
+	Responses  []*Response `json:"responses"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// responseCursor marks the last response returned so the next page can continue after it
+type responseCursor struct {
+	ID int64 `json:"id"`
+}
+
+func encodeResponseCursor(cursor responseCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeResponseCursor(s string) (*responseCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrResponseQuery)
+	}
+	var cursor responseCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrResponseQuery)
+	}
+	return &cursor, nil
+}
+
+// visibleTo limits responses r to elements e whose visibility in the form's current version lets
+// the audience see them, so changing an element's visibility also applies to earlier answers.
+// Sensitive elements are left out for every audience.
+func visibleTo(audience string) (string, []interface{}) {
+	visible := forms.VisibleTo(audience)
+	args := make([]interface{}, len(visible))
+	for i, visibility := range visible {
+		args[i] = visibility
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(visible)), ", ")
+	return "exists (select 1 from elements ve, forms vf where ve.formID = vf.id and ve.versionID = vf.currentVersionID " +
+		"and ve.originID = e.originID and ve.sensitive = false and ve.visibility in (" + placeholders + "))", args
+}
+
+// where builds the conditions of the filter, for responses r joined to their elements e
+func (f *ResponseFilter) where() (string, []interface{}, error) {
+	conditions := []string{"r.elementID = e.id"}
+	var args []interface{}
+	if f.Approved != nil && *f.Approved {
+		conditions = append(conditions, latestApprovedOnly)
+	} else {
+		conditions = append(conditions, latestOnly)
+	}
+	if f.Approved != nil && !*f.Approved {
+		conditions = append(conditions, "r.approved = false")
+	}
+	if f.Reviewed != nil && *f.Reviewed {
+		conditions = append(conditions, "r.reviewedAt is not null")
+	}
+	if f.Reviewed != nil && !*f.Reviewed {
+		conditions = append(conditions, "r.reviewedAt is null")
+	}
+	if f.ReviewStatus != "" {
+		if !validReviewStatus(ReviewStatus(f.ReviewStatus)) {
+			return "", nil, fmt.Errorf("%w: unknown review status %q", ErrResponseQuery, f.ReviewStatus)
+		}
+		conditions = append(conditions, "r.reviewStatus = ?")
+		args = append(args, f.ReviewStatus)
+	}
+	if f.UserID != 0 {
+		conditions = append(conditions, "r.userID = ?")
+		args = append(args, f.UserID)
+	}
+	if f.FormID != 0 {
+		conditions = append(conditions, "e.formID = ?")
+		args = append(args, f.FormID)
+	}
+	if f.ElementID != 0 {
+		conditions = append(conditions, "e.originID = (select originID from elements where id = ?)")
+		args = append(args, f.ElementID)
+	}
+	if !f.CreatedAfter.IsZero() {
+		conditions = append(conditions, "r.createdAt >= ?")
+		args = append(args, f.CreatedAfter)
+	}
+	if !f.CreatedBefore.IsZero() {
+		if !f.CreatedAfter.IsZero() && f.CreatedBefore.Before(f.CreatedAfter) {
+			return "", nil, fmt.Errorf("%w: created_before is before created_after", ErrResponseQuery)
+		}
+		conditions = append(conditions, "r.createdAt < ?")
+		args = append(args, f.CreatedBefore)
+	}
+	if f.Audience != "" {
+		scope, scopeArgs := visibleTo(f.Audience)
+		conditions = append(conditions, scope)
+		args = append(args, scopeArgs...)
+	}
+	if f.Cursor != "" {
+		cursor, err := decodeResponseCursor(f.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "r.id > ?")
+		args = append(args, cursor.ID)
+	}
+	return strings.Join(conditions, " and "), args, nil
+}
+
+// GetResponses returns a page of each user's latest responses matching the filter, with values
+// opened with keys (see openValue)
+func GetResponses(filter *ResponseFilter, keys *encryption.Keyring, db *sql.DB) (*ResponsePage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = defaultResponseLimit
+	}
+	if filter.Limit > maxResponseLimit {
+		filter.Limit = maxResponseLimit
+	}
+	where, args, err := filter.where()
+	if err != nil {
+		return nil, err
+	}
+	selectResponses := "select " + responseColumns + " from responses r, elements e where " + where + " order by r.id limit ?"
+	args = append(args, filter.Limit+1)
+	rows, err := db.Query(selectResponses, args...)
+	if err != nil {
+		return nil, errors.New("error selecting responses: " + err.Error())
+	}
+	defer rows.Close()
+	page := ResponsePage{Responses: []*Response{}}
+	for rows.Next() {
+		if len(page.Responses) == filter.Limit {
+			// there is at least one more response after this page
+			page.NextCursor = encodeResponseCursor(responseCursor{ID: page.Responses[len(page.Responses)-1].ID})
+			break
+		}
+		var resp sqlResponse
+		err := rows.Scan(resp.fields()...)
+		if err != nil {
+			return nil, errors.New("error scanning response: " + err.Error())
+		}
+		page.Responses = append(page.Responses, resp.ToResponse())
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, errors.New("error selecting responses: " + err.Error())
+	}
+	err = addResponseOptions(page.Responses, db)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Audience != "" {
+		// reviews are between reviewers and the submitter
+		for _, response := range page.Responses {
+			response.Review = nil
+		}
+	} else {
+		err = addReviewComments(page.Responses, db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = openResponses(page.Responses, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// addResponseOptions loads the chosen options of all the responses in one query
+func addResponseOptions(responses []*Response, db *sql.DB) error {
+	if len(responses) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Response, len(responses))
+	placeholders := make([]string, len(responses))
+	args := make([]interface{}, len(responses))
+	for i, response := range responses {
+		byID[response.ID] = response
+		placeholders[i] = "?"
+		args[i] = response.ID
+	}
+	rows, err := db.Query("select responseID, optionID from response_options where responseID in ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return errors.New("error selecting response options: " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var responseID, optionID int64
+		err := rows.Scan(&responseID, &optionID)
+		if err != nil {
+			return errors.New("error scanning response option: " + err.Error())
+		}
+		response := byID[responseID]
+		response.OptionIDs = append(response.OptionIDs, optionID)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package responses
+
+import (
+	"api/forms"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResponseFilterWhere(t *testing.T) {
+	approved := false
+	after := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	filter := ResponseFilter{
+		UserID:       3,
+		FormID:       4,
+		Approved:     &approved,
+		CreatedAfter: after,
+		Cursor:       encodeResponseCursor(responseCursor{ID: 10}),
+	}
+	where, args, err := filter.where()
+	if err != nil {
+		t.Fatal("failed to build filter: " + err.Error())
+	}
+	for _, condition := range []string{latestOnly, "r.approved = false", "r.userID = ?", "e.formID = ?", "r.createdAt >= ?", "r.id > ?"} {
+		if !strings.Contains(where, condition) {
+			t.Errorf("expected %q in %q", condition, where)
+		}
+	}
+	if len(args) != 4 || args[0] != int64(3) || args[1] != int64(4) || args[2] != after || args[3] != int64(10) {
+		t.Error("unexpected filter arguments", args)
+	}
+
+	approved = true
+	where, _, err = filter.where()
+	if err != nil {
+		t.Fatal("failed to build filter: " + err.Error())
+	}
+	if !strings.Contains(where, latestApprovedOnly) || strings.Contains(where, "r.approved = false") {
+		t.Error("expected only latest approved responses; got", where)
+	}
+
+	filter.Audience = forms.VisibilityProviders
+	where, args, err = filter.where()
+	if err != nil {
+		t.Fatal("failed to build filter: " + err.Error())
+	}
+	if !strings.Contains(where, "ve.visibility in (?, ?)") || len(args) != 6 || args[3] != forms.VisibilityPublic || args[4] != forms.VisibilityProviders {
+		t.Error("expected responses visible to approved providers; got", where, args)
+	}
+
+	filter.CreatedBefore = after.Add(-time.Hour)
+	_, _, err = filter.where()
+	if !errors.Is(err, ErrResponseQuery) {
+		t.Error("expected an empty date range to be rejected; got", err)
+	}
+}
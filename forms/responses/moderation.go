@@ -0,0 +1,291 @@
+package responses
+
+import (
+	"api/encryption"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Responses wait for review until an admin approves, rejects or asks for changes to them, and go
+// back to waiting when their answer is edited or they are resubmitted. Every decision is kept in
+// response_decisions.
+
+const maxDecisionResponses = 500
+
+// ErrDecision is wrapped by errors for moderation decisions that can't be made
+var ErrDecision = errors.New("invalid moderation decision")
+
+// ResponseDecision is an admin's review of a response
+type ResponseDecision struct {
+	ID         int64        `json:"id"`
+	ResponseID int64        `json:"response_id"`
+	Status     ReviewStatus `json:"status"`
+	Approved   bool         `json:"approved"`
+	Note       string       `json:"note"`
+	ActorID    int64        `json:"actor_id"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// DecisionRequest reviews many responses at once. Approved is shorthand for the approved and
+// rejected statuses.
+type DecisionRequest struct {
+	ResponseIDs []int64      `json:"response_ids"`
+	Status      ReviewStatus `json:"status"`
+	Approved    *bool        `json:"approved"`
+	Note        string       `json:"note"`
+}
+
+// DecisionStatus returns the status the request moves responses to
+func (r *DecisionRequest) DecisionStatus() (ReviewStatus, error) {
+	if r.Status != "" {
+		if r.Approved != nil && *r.Approved != (r.Status == ReviewApproved) {
+			return "", fmt.Errorf("%w: approved doesn't match status", ErrDecision)
+		}
+		return r.Status, nil
+	}
+	if r.Approved == nil {
+		return "", fmt.Errorf("%w: status is required", ErrDecision)
+	}
+	if *r.Approved {
+		return ReviewApproved, nil
+	}
+	return ReviewRejected, nil
+}
+
+// ModerationItem is a response waiting for review, with its question and options resolved
+type ModerationItem struct {
+	*Response
+	Label   string   `json:"label"`
+	Options []string `json:"options"`
+}
+
+// ModerationGroup holds one user's responses to one form
+type ModerationGroup struct {
+	UserID    int64             `json:"user_id"`
+	UserName  string            `json:"user_name"`
+	UserEmail string            `json:"user_email"`
+	FormID    int64             `json:"form_id"`
+	FormName  string            `json:"form_name"`
+	Responses []*ModerationItem `json:"responses"`
+}
+
+// ModerationPage is one page of the moderation queue. A group can continue on the next page.
+type ModerationPage struct {
+	Groups     []*ModerationGroup `json:"groups"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// idPlaceholders returns placeholders and arguments for an in clause
+func idPlaceholders(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// DecideResponses moves responses to a review status in one transaction, recording who made the
+// decision and when and updating the provider directory index. A note is also added to each
+// response's review thread. If any response doesn't exist nothing is changed.
+func DecideResponses(ids []int64, status ReviewStatus, note string, actorID int64, db *sql.DB) ([]*ResponseDecision, error) {
+	if !validDecision(status) {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrDecision, status)
+	}
+	approved := status == ReviewApproved
+	note = strings.TrimSpace(note)
+	unique := []int64{}
+	seen := map[int64]bool{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("%w: no responses given", ErrDecision)
+	}
+	if len(unique) > maxDecisionResponses {
+		return nil, fmt.Errorf("%w: at most %d responses can be decided at once", ErrDecision, maxDecisionResponses)
+	}
+	placeholders, args := idPlaceholders(unique)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query("select id from responses where id in ("+placeholders+") for update", args...)
+	if err != nil {
+		return nil, errors.New("error selecting responses: " + err.Error())
+	}
+	found := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return nil, errors.New("error scanning response: " + err.Error())
+		}
+		found[id] = true
+	}
+	rows.Close()
+	for _, id := range unique {
+		if !found[id] {
+			return nil, fmt.Errorf("%w: response %v", ErrResponseNotFound, id)
+		}
+	}
+
+	now := time.Now()
+	_, err = tx.Exec("update responses set reviewStatus = ?, approved = ?, reviewedAt = ? where id in ("+placeholders+")", append([]interface{}{status, approved, now}, args...)...)
+	if err != nil {
+		return nil, errors.New("error updating responses: " + err.Error())
+	}
+	decisions := []*ResponseDecision{}
+	for _, id := range unique {
+		decision := ResponseDecision{ResponseID: id, Status: status, Approved: approved, Note: note, ActorID: actorID, CreatedAt: now}
+		result, err := tx.Exec(
+			"insert into response_decisions (responseID, status, approved, note, actorID, createdAt) values (?, ?, ?, ?, ?, ?)",
+			id, status, approved, sql.NullString{String: note, Valid: note != ""}, sql.NullInt64{Int64: actorID, Valid: actorID != 0}, now,
+		)
+		if err != nil {
+			return nil, errors.New("error inserting response decision: " + err.Error())
+		}
+		decision.ID, err = result.LastInsertId()
+		if err != nil {
+			return nil, errors.New("error getting response decision id: " + err.Error())
+		}
+		decisions = append(decisions, &decision)
+		if note != "" {
+			err = insertComment(&ReviewComment{ResponseID: id, AuthorID: actorID, Body: note, Status: status, CreatedAt: now}, tx)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	// the index changes with the decisions, so a failure leaves both as they were
+	err = reindexResponses(unique, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing decisions: " + err.Error())
+	}
+	return decisions, nil
+}
+
+// GetModerationQueue returns a page of each user's latest responses that haven't been reviewed,
+// grouped by user and form in the order they were submitted
+func GetModerationQueue(filter *ResponseFilter, keys *encryption.Keyring, db *sql.DB) (*ModerationPage, error) {
+	reviewed := false
+	filter.Reviewed = &reviewed
+	responses, err := GetResponses(filter, keys, db)
+	if err != nil {
+		return nil, err
+	}
+	page := ModerationPage{Groups: []*ModerationGroup{}, NextCursor: responses.NextCursor}
+	if len(responses.Responses) == 0 {
+		return &page, nil
+	}
+
+	var elementIDs, optionIDs, userIDs, formIDs []int64
+	for _, response := range responses.Responses {
+		elementIDs = append(elementIDs, response.ElementID)
+		optionIDs = append(optionIDs, response.OptionIDs...)
+		userIDs = append(userIDs, response.UserID)
+		formIDs = append(formIDs, response.FormID)
+	}
+	labels, err := getNames("select id, label from elements where id in", elementIDs, db)
+	if err != nil {
+		return nil, err
+	}
+	optionNames, err := getOptionNames(optionIDs, db)
+	if err != nil {
+		return nil, err
+	}
+	formNames, err := getNames("select id, name from forms where id in", formIDs, db)
+	if err != nil {
+		return nil, err
+	}
+	users, err := getModerationUsers(userIDs, db)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := map[[2]int64]*ModerationGroup{}
+	for _, response := range responses.Responses {
+		key := [2]int64{response.UserID, response.FormID}
+		group, ok := groups[key]
+		if !ok {
+			user := users[response.UserID]
+			group = &ModerationGroup{
+				UserID:    response.UserID,
+				UserName:  user.name,
+				UserEmail: user.email,
+				FormID:    response.FormID,
+				FormName:  formNames[response.FormID],
+				Responses: []*ModerationItem{},
+			}
+			groups[key] = group
+			page.Groups = append(page.Groups, group)
+		}
+		item := ModerationItem{Response: response, Label: labels[response.ElementID], Options: []string{}}
+		for _, optionID := range response.OptionIDs {
+			item.Options = append(item.Options, optionNames[optionID])
+		}
+		group.Responses = append(group.Responses, &item)
+	}
+	return &page, nil
+}
+
+// getNames runs selectNames, which selects an id and name for rows whose id is in a list left
+// open at the end of the query
+func getNames(selectNames string, ids []int64, db *sql.DB) (map[int64]string, error) {
+	placeholders, args := idPlaceholders(ids)
+	rows, err := db.Query(selectNames+" ("+placeholders+")", args...)
+	if err != nil {
+		return nil, errors.New("error selecting names: " + err.Error())
+	}
+	defer rows.Close()
+	names := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var name string
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return nil, errors.New("error scanning name: " + err.Error())
+		}
+		names[id] = name
+	}
+	return names, nil
+}
+
+type moderationUser struct {
+	name  string
+	email string
+}
+
+func getModerationUsers(ids []int64, db *sql.DB) (map[int64]moderationUser, error) {
+	placeholders, args := idPlaceholders(ids)
+	rows, err := db.Query("select id, firstName, lastName, email from users where id in ("+placeholders+")", args...)
+	if err != nil {
+		return nil, errors.New("error selecting users: " + err.Error())
+	}
+	defer rows.Close()
+	users := map[int64]moderationUser{}
+	for rows.Next() {
+		var id int64
+		var firstName, lastName sql.NullString
+		var user moderationUser
+		err := rows.Scan(&id, &firstName, &lastName, &user.email)
+		if err != nil {
+			return nil, errors.New("error scanning user: " + err.Error())
+		}
+		user.name = strings.TrimSpace(firstName.String + " " + lastName.String)
+		users[id] = user
+	}
+	return users, nil
+}
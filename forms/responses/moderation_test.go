@@ -0,0 +1,57 @@
+package responses
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecideResponsesValidation(t *testing.T) {
+	_, err := DecideResponses(nil, ReviewApproved, "", 1, nil)
+	if !errors.Is(err, ErrDecision) {
+		t.Error("expected no responses to be rejected; got", err)
+	}
+	ids := make([]int64, maxDecisionResponses+1)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+	_, err = DecideResponses(ids, ReviewApproved, "", 1, nil)
+	if !errors.Is(err, ErrDecision) {
+		t.Error("expected too many responses to be rejected; got", err)
+	}
+	_, err = DecideResponses([]int64{1}, ReviewPending, "", 1, nil)
+	if !errors.Is(err, ErrDecision) {
+		t.Error("expected pending not to be a decision; got", err)
+	}
+}
+
+func TestUnreviewedFilter(t *testing.T) {
+	reviewed := false
+	where, _, err := (&ResponseFilter{Reviewed: &reviewed}).where()
+	if err != nil {
+		t.Fatal("failed to build filter: " + err.Error())
+	}
+	if !strings.Contains(where, "r.reviewedAt is null") {
+		t.Error("expected only unreviewed responses; got", where)
+	}
+}
+
+func TestDecisionStatus(t *testing.T) {
+	approved := true
+	status, err := (&DecisionRequest{Approved: &approved}).DecisionStatus()
+	if err != nil || status != ReviewApproved {
+		t.Error("expected approved to be shorthand for the approved status; got", status, err)
+	}
+	status, err = (&DecisionRequest{Status: ReviewChangesRequested}).DecisionStatus()
+	if err != nil || status != ReviewChangesRequested {
+		t.Error("expected the status as it is; got", status, err)
+	}
+	_, err = (&DecisionRequest{Status: ReviewRejected, Approved: &approved}).DecisionStatus()
+	if !errors.Is(err, ErrDecision) {
+		t.Error("expected a conflicting approval to be rejected; got", err)
+	}
+	_, err = (&DecisionRequest{}).DecisionStatus()
+	if !errors.Is(err, ErrDecision) {
+		t.Error("expected a status to be required; got", err)
+	}
+}
@@ -0,0 +1,100 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/users"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ProviderProfile is a provider along with their approved answers, ready to display
+type ProviderProfile struct {
+	Provider *users.Provider `json:"provider"`
+	Forms    []*ProfileForm  `json:"forms"`
+}
+
+type ProfileForm struct {
+	FormID   int64            `json:"form_id"`
+	FormName string           `json:"form_name"`
+	Answers  []*ProfileAnswer `json:"answers"`
+}
+
+// ProfileAnswer is an approved answer to an element, with option IDs resolved to names
+type ProfileAnswer struct {
+	ElementID int64    `json:"element_id"`
+	Label     string   `json:"label"`
+	Type      string   `json:"type"`
+	Position  int      `json:"position"`
+	Value     string   `json:"value,omitempty"`
+	Options   []string `json:"options,omitempty"`
+}
+
+// GetProviderProfile returns an approved provider and their latest approved answer to each
+// element the audience can see, grouped by form with elements in position order. Answers keep
+// the wording of the form version they were given in.
+func GetProviderProfile(providerID int64, audience string, db *sql.DB) (*ProviderProfile, error) {
+	provider, err := users.GetApprovedProvider(&providerID, db)
+	if err != nil {
+		return nil, err
+	}
+	profile := ProviderProfile{Provider: provider, Forms: []*ProfileForm{}}
+
+	scope, scopeArgs := visibleTo(audience)
+	selectAnswers := "select r.id, f.id, f.name, e.id, e.label, e.type, e.position, r.value from responses r, elements e, forms f " +
+		"where r.elementID = e.id and e.formID = f.id and f.deletedAt is null and r.userID = ? and r.approved = true " +
+		"and r.id = (select max(r2.id) from responses r2, elements e2 where r2.elementID = e2.id and e2.originID = e.originID and r2.userID = r.userID and r2.approved = true) " +
+		"and " + scope + " order by f.id, e.position, e.id"
+	rows, err := db.Query(selectAnswers, append([]interface{}{providerID}, scopeArgs...)...)
+	if err != nil {
+		return nil, errors.New("error selecting profile answers: " + err.Error())
+	}
+	defer rows.Close()
+	answers := map[int64]*ProfileAnswer{}
+	var responseIDs []interface{}
+	for rows.Next() {
+		var responseID, formID int64
+		var formName string
+		var value sql.NullString
+		var answer ProfileAnswer
+		err := rows.Scan(&responseID, &formID, &formName, &answer.ElementID, &answer.Label, &answer.Type, &answer.Position, &value)
+		if err != nil {
+			return nil, errors.New("error scanning profile answer: " + err.Error())
+		}
+		answer.Value = value.String
+		if encryption.IsSealed(answer.Value) {
+			// the element was sensitive when this was answered; profiles are public
+			answer.Value = ""
+		}
+		last := len(profile.Forms) - 1
+		if last < 0 || profile.Forms[last].FormID != formID {
+			profile.Forms = append(profile.Forms, &ProfileForm{FormID: formID, FormName: formName})
+			last++
+		}
+		profile.Forms[last].Answers = append(profile.Forms[last].Answers, &answer)
+		answers[responseID] = &answer
+		responseIDs = append(responseIDs, responseID)
+	}
+	if len(responseIDs) == 0 {
+		return &profile, nil
+	}
+
+	// resolve the options for every answer in one query
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(responseIDs)), ", ")
+	selectOptions := "select ro.responseID, o.name from response_options ro, options o where ro.optionID = o.id and ro.responseID in (" + placeholders + ") order by ro.responseID, o.position"
+	optionRows, err := db.Query(selectOptions, responseIDs...)
+	if err != nil {
+		return nil, errors.New("error selecting profile options: " + err.Error())
+	}
+	defer optionRows.Close()
+	for optionRows.Next() {
+		var responseID int64
+		var name string
+		err := optionRows.Scan(&responseID, &name)
+		if err != nil {
+			return nil, errors.New("error scanning profile option: " + err.Error())
+		}
+		answers[responseID].Options = append(answers[responseID].Options, name)
+	}
+	return &profile, nil
+}
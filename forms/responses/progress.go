@@ -0,0 +1,215 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SectionProgress is how far a user has got with one section of a form
+type SectionProgress struct {
+	SectionID int64      `json:"section_id"` // 0 for the implicit section
+	Title     string     `json:"title"`
+	Position  int        `json:"position"`
+	SavedAt   *time.Time `json:"saved_at"` // when the user last saved the section, in any version of the form
+	Missing   []int64    `json:"missing"`  // required elements shown to the user that aren't answered
+	Complete  bool       `json:"complete"`
+}
+
+type FormProgress struct {
+	FormID   int64              `json:"form_id"`
+	Version  int                `json:"version"`
+	Sections []*SectionProgress `json:"sections"`
+	Complete bool               `json:"complete"`
+}
+
+// latestAnswers returns the user's most recent answer to each of the form's elements, keyed by
+// element ID. Answers to earlier versions of the form carry over through element and option
+// origins; options that are no longer on the form are dropped.
+func latestAnswers(form *forms.Form, userID int64, keys *encryption.Keyring, db *sql.DB) (map[int64]*forms.GivenAnswer, error) {
+	elements := map[int64]*forms.Element{}
+	options := map[int64]int64{}
+	for _, element := range form.Elements {
+		elements[element.OriginID] = element
+		for _, option := range element.Options {
+			options[option.OriginID] = option.ID
+		}
+	}
+	selectAnswers := "select r.id, e.originID, r.value from responses r, elements e where r.elementID = e.id and e.formID = ? and r.userID = ? " +
+		"and r.id = (select max(r2.id) from responses r2, elements e2 where r2.elementID = e2.id and e2.originID = e.originID and r2.userID = r.userID)"
+	rows, err := db.Query(selectAnswers, form.ID, userID)
+	if err != nil {
+		return nil, errors.New("error getting latest answers: " + err.Error())
+	}
+	defer rows.Close()
+	answers := map[int64]*forms.GivenAnswer{}
+	byResponse := map[int64]*forms.GivenAnswer{}
+	for rows.Next() {
+		var responseID, originID int64
+		var value sql.NullString
+		err := rows.Scan(&responseID, &originID, &value)
+		if err != nil {
+			return nil, errors.New("error scanning latest answer: " + err.Error())
+		}
+		element, ok := elements[originID]
+		if !ok {
+			continue
+		}
+		opened, err := openValue(value.String, keys)
+		if err != nil {
+			return nil, err
+		}
+		answer := &forms.GivenAnswer{Value: opened}
+		answers[element.ID] = answer
+		byResponse[responseID] = answer
+	}
+	if len(byResponse) == 0 {
+		return answers, nil
+	}
+
+	selectOptions := "select ro.responseID, o.originID from response_options ro, options o, responses r, elements e " +
+		"where ro.optionID = o.id and ro.responseID = r.id and r.elementID = e.id and e.formID = ? and r.userID = ?"
+	optionRows, err := db.Query(selectOptions, form.ID, userID)
+	if err != nil {
+		return nil, errors.New("error getting latest answer options: " + err.Error())
+	}
+	defer optionRows.Close()
+	for optionRows.Next() {
+		var responseID, originID int64
+		err := optionRows.Scan(&responseID, &originID)
+		if err != nil {
+			return nil, errors.New("error scanning latest answer option: " + err.Error())
+		}
+		answer, ok := byResponse[responseID]
+		optionID, current := options[originID]
+		if ok && current {
+			answer.OptionIDs = append(answer.OptionIDs, optionID)
+		}
+	}
+	return answers, nil
+}
+
+// saveProgress records that the user has saved each of the sections
+func saveProgress(form *forms.Form, sections []*forms.Section, userID int64, savedAt time.Time, tx *sql.Tx) error {
+	for _, section := range sections {
+		_, err := tx.Exec(
+			"insert into section_progress (userID, formID, sectionID, formVersion, savedAt) values (?, ?, ?, ?, ?) "+
+				"on duplicate key update formVersion = values(formVersion), savedAt = values(savedAt)",
+			userID, form.ID, section.OriginID, form.Version, savedAt,
+		)
+		if err != nil {
+			return errors.New("error saving section progress: " + err.Error())
+		}
+	}
+	return nil
+}
+
+func findSection(form *forms.Form, sectionID int64) (*forms.Section, error) {
+	for _, section := range form.Sections {
+		if section.ID == sectionID {
+			return section, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: section %v of form %v", forms.ErrSectionNotFound, sectionID, form.ID)
+}
+
+// SubmitSection validates and saves a user's answers to one section of a live form, so long
+// forms can be filled in a page at a time. Conditions that depend on other sections use the
+// user's latest answers to them. If any answer is invalid nothing is saved and the error is
+// ValidationErrors.
+func SubmitSection(formID int64, sectionID int64, userID int64, submission *Submission, keys *encryption.Keyring, db *sql.DB) ([]*Response, error) {
+	err := validateUser(userID, db)
+	if err != nil {
+		return nil, err
+	}
+	form, err := forms.GetForm(formID, true, db)
+	if err != nil {
+		return nil, err
+	}
+	section, err := findSection(form, sectionID)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := latestAnswers(form, userID, keys, db)
+	if err != nil {
+		return nil, err
+	}
+	invalid := validateAnswers(form, section.Elements, submission.Answers, previous)
+	if invalid != nil {
+		return nil, invalid
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	createdAt := time.Now()
+	saved, err := insertAnswers(form, userID, submission.Answers, createdAt, keys, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = saveProgress(form, []*forms.Section{section}, userID, createdAt, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing section: " + err.Error())
+	}
+	return saved, nil
+}
+
+// GetFormProgress returns which sections of a live form the user has saved and still needs to answer
+func GetFormProgress(formID int64, userID int64, keys *encryption.Keyring, db *sql.DB) (*FormProgress, error) {
+	form, err := forms.GetForm(formID, true, db)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.Query("select sectionID, savedAt from section_progress where userID = ? and formID = ?", userID, formID)
+	if err != nil {
+		return nil, errors.New("error getting section progress: " + err.Error())
+	}
+	defer rows.Close()
+	savedAt := map[int64]time.Time{}
+	for rows.Next() {
+		var sectionID int64
+		var saved time.Time
+		err := rows.Scan(&sectionID, &saved)
+		if err != nil {
+			return nil, errors.New("error scanning section progress: " + err.Error())
+		}
+		savedAt[sectionID] = saved
+	}
+	answers, err := latestAnswers(form, userID, keys, db)
+	if err != nil {
+		return nil, err
+	}
+	visible := form.VisibleElements(answers)
+
+	progress := FormProgress{FormID: form.ID, Version: form.Version, Sections: []*SectionProgress{}, Complete: true}
+	for _, section := range form.Sections {
+		sectionProgress := SectionProgress{
+			SectionID: section.ID,
+			Title:     section.Title,
+			Position:  section.Position,
+			Missing:   []int64{},
+		}
+		if saved, ok := savedAt[section.OriginID]; ok {
+			sectionProgress.SavedAt = &saved
+		}
+		for _, element := range section.Elements {
+			answer := answers[element.ID]
+			if element.Required && visible[element.ID] && (answer == nil || (answer.Value == "" && len(answer.OptionIDs) == 0)) {
+				sectionProgress.Missing = append(sectionProgress.Missing, element.ID)
+			}
+		}
+		sectionProgress.Complete = sectionProgress.SavedAt != nil && len(sectionProgress.Missing) == 0
+		progress.Complete = progress.Complete && sectionProgress.Complete
+		progress.Sections = append(progress.Sections, &sectionProgress)
+	}
+	return &progress, nil
+}
@@ -1,7 +1,9 @@
 package responses
 
 import (
+	"api/encryption"
 	"api/env"
+	"api/forms"
 	"api/users"
 	"database/sql"
 	"errors"
@@ -10,51 +12,78 @@ import (
 )
 
 type Response struct {
-	ID        int64     `json:"id"`
-	FormID    int64     `json:"form_id"`
-	ElementID int64     `json:"element_id"`
-	UserID    int64     `json:"user_id"`
-	Value     string    `json:"value"`
-	OptionIDs []int64   `json:"option_ids"`
-	CreatedAt time.Time `json:"created_at"`
-	Approved  bool      `json:"approved"`
+	ID          int64     `json:"id"`
+	FormID      int64     `json:"form_id"`
+	ElementID   int64     `json:"element_id"`
+	UserID      int64     `json:"user_id"`
+	Value       string    `json:"value"`
+	OptionIDs   []int64   `json:"option_ids"`
+	CreatedAt   time.Time `json:"created_at"`
+	Approved    bool      `json:"approved"`
+	FormVersion int       `json:"form_version"` // the version of the form that was answered
+	Review      *Review   `json:"review,omitempty"`
 }
 
 type sqlResponse struct {
 	Response
-	Value sql.NullString
+	Value        sql.NullString
+	FormVersion  sql.NullInt32
+	ReviewStatus string
+	ReviewedAt   sql.NullTime
 }
 
 func (r *sqlResponse) ToResponse() *Response {
 	resp := &Response{
-		ID:        r.ID,
-		FormID:    r.FormID,
-		ElementID: r.ElementID,
-		UserID:    r.UserID,
-		Value:     r.Value.String,
-		OptionIDs: r.OptionIDs,
-		CreatedAt: r.CreatedAt,
-		Approved:  r.Approved,
+		ID:          r.ID,
+		FormID:      r.FormID,
+		ElementID:   r.ElementID,
+		UserID:      r.UserID,
+		Value:       r.Value.String,
+		OptionIDs:   r.OptionIDs,
+		CreatedAt:   r.CreatedAt,
+		Approved:    r.Approved,
+		FormVersion: int(r.FormVersion.Int32),
+		Review:      &Review{Status: ReviewStatus(r.ReviewStatus), Comments: []*ReviewComment{}},
+	}
+	if r.ReviewedAt.Valid {
+		resp.Review.ReviewedAt = &r.ReviewedAt.Time
 	}
 	return resp
 }
 
+const responseColumns = "r.id, e.formID, r.elementID, r.userID, r.value, r.createdAt, r.approved, r.formVersion, r.reviewStatus, r.reviewedAt"
+
+// fields returns pointers to scan responseColumns into
+func (r *sqlResponse) fields() []interface{} {
+	return []interface{}{&r.ID, &r.FormID, &r.ElementID, &r.UserID, &r.Value, &r.CreatedAt, &r.Approved, &r.FormVersion, &r.ReviewStatus, &r.ReviewedAt}
+}
+
+// Users can answer an element more than once, and answers to earlier versions of a form carry
+// over to later ones. Reads only return each user's latest response to an element, or their
+// latest approved response when only approved responses are wanted.
+const (
+	latestOnly         = "r.id = (select max(r2.id) from responses r2, elements e2 where r2.elementID = e2.id and e2.originID = e.originID and r2.userID = r.userID)"
+	latestApprovedOnly = "r.id = (select max(r2.id) from responses r2, elements e2 where r2.elementID = e2.id and e2.originID = e.originID and r2.userID = r.userID and r2.approved = true)"
+)
+
 type FormResponse struct {
 	FormID         int64     `json:"form_id"`
 	FormName       string    `json:"form_name"`
 	LastResponseAt time.Time `json:"last_responded_time"`
 }
 
-func NewResponse(elementID int64, userID int64, value string, db *sql.DB) (*Response, error) {
-	// validate element exists
-	selectElement := "SELECT id FROM elements WHERE id = ?"
-	var selectedElement int64
-	err := db.QueryRow(selectElement, elementID).Scan(&selectedElement)
+func NewResponse(elementID int64, userID int64, value string, keys *encryption.Keyring, db *sql.DB) (*Response, error) {
+	element, err := forms.GetElement(elementID, db)
 	if err != nil {
-		return nil, fmt.Errorf("error selecting element %v: %s", elementID, err.Error())
+		return nil, err
 	}
-	if selectedElement == 0 {
-		return nil, fmt.Errorf("element %v not found", elementID)
+	value, err = element.ValidateAnswer(value, nil)
+	if err != nil {
+		return nil, err
+	}
+	version, err := forms.AnswerableVersion(element, db)
+	if err != nil {
+		return nil, err
 	}
 
 	// validate user
@@ -64,12 +93,18 @@ func NewResponse(elementID int64, userID int64, value string, db *sql.DB) (*Resp
 	}
 
 	resp := &Response{
-		ElementID: elementID,
-		UserID:    userID,
-		Value:     value,
-		CreatedAt: time.Now(),
+		FormID:      element.FormID,
+		ElementID:   elementID,
+		UserID:      userID,
+		Value:       value,
+		CreatedAt:   time.Now(),
+		FormVersion: version,
+	}
+	stored, err := sealAnswer(element, value, keys)
+	if err != nil {
+		return nil, err
 	}
-	result, err := db.Exec("INSERT INTO responses (elementID, userID, value, createdAt) VALUES (?, ?, ?, ?)", elementID, userID, value, resp.CreatedAt)
+	result, err := db.Exec("INSERT INTO responses (elementID, userID, value, createdAt, formVersion) VALUES (?, ?, ?, ?, ?)", elementID, userID, stored, resp.CreatedAt, version)
 	if err != nil {
 		return nil, errors.New("error inserting response: " + err.Error())
 	}
@@ -77,15 +112,20 @@ func NewResponse(elementID int64, userID int64, value string, db *sql.DB) (*Resp
 	if err != nil {
 		return nil, errors.New("error getting last insert id: " + err.Error())
 	}
-	resp.FormID, err = getElementFormID(elementID, db)
-	if err != nil {
-		return nil, errors.New("error getting response form id: " + err.Error())
-	}
 	return resp, nil
 }
 
 func NewResponseWithOptions(elementID int64, userID int64, optionIDs []int64, db *sql.DB) (*Response, error) {
-	err := validateElement(elementID, db)
+	element, err := forms.GetElement(elementID, db)
+	if err != nil {
+		return nil, err
+	}
+	// this also checks that each option belongs to the element
+	_, err = element.ValidateAnswer("", optionIDs)
+	if err != nil {
+		return nil, err
+	}
+	version, err := forms.AnswerableVersion(element, db)
 	if err != nil {
 		return nil, err
 	}
@@ -94,12 +134,19 @@ func NewResponseWithOptions(elementID int64, userID int64, optionIDs []int64, db
 		return nil, err
 	}
 	resp := &Response{
-		ElementID: elementID,
-		UserID:    userID,
-		OptionIDs: optionIDs,
-		CreatedAt: time.Now(),
+		FormID:      element.FormID,
+		ElementID:   elementID,
+		UserID:      userID,
+		OptionIDs:   optionIDs,
+		CreatedAt:   time.Now(),
+		FormVersion: version,
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
 	}
-	result, err := db.Exec("INSERT INTO responses (elementID, userID, createdAt) VALUES (?, ?, ?)", elementID, userID, resp.CreatedAt)
+	defer tx.Rollback()
+	result, err := tx.Exec("INSERT INTO responses (elementID, userID, createdAt, formVersion) VALUES (?, ?, ?, ?)", elementID, userID, resp.CreatedAt, version)
 	if err != nil {
 		return nil, errors.New("error inserting response: " + err.Error())
 	}
@@ -110,35 +157,26 @@ func NewResponseWithOptions(elementID int64, userID int64, optionIDs []int64, db
 
 	// insert response options
 	for _, optionID := range optionIDs {
-		// validate the option exists for the element
-		selectOption := "SELECT id FROM options WHERE id = ? AND elementID = ?"
-		var selectedOption int64
-		err := db.QueryRow(selectOption, optionID, elementID).Scan(&selectedOption)
-		if err != nil {
-			return nil, fmt.Errorf("error selecting option %v for element %v: %s", optionID, elementID, err.Error())
-		}
-		if selectedOption == 0 {
-			return nil, fmt.Errorf("option %v not found for element %v", optionID, elementID)
-		}
-
-		_, err = db.Exec("INSERT INTO response_options (responseID, optionID) VALUES (?, ?)", resp.ID, optionID)
+		_, err = tx.Exec("INSERT INTO response_options (responseID, optionID) VALUES (?, ?)", resp.ID, optionID)
 		if err != nil {
 			return nil, errors.New("error inserting response options: " + err.Error())
 		}
 	}
-
-	resp.FormID, err = getElementFormID(resp.ElementID, db)
+	err = tx.Commit()
 	if err != nil {
-		return nil, errors.New("error getting response form id: " + err.Error())
+		return nil, errors.New("error committing response: " + err.Error())
 	}
-
 	return resp, nil
 }
 
-func GetResponse(id int64, db *sql.DB) (*Response, error) {
-	selectResponse := "SELECT id, elementID, userID, value, createdAt, approved FROM responses WHERE id = ?"
+// GetResponse returns a response with its value opened with keys, see openValue
+func GetResponse(id int64, keys *encryption.Keyring, db *sql.DB) (*Response, error) {
+	selectResponse := "SELECT " + responseColumns + " FROM responses r, elements e WHERE r.elementID = e.id AND r.id = ?"
 	var resp sqlResponse
-	err := db.QueryRow(selectResponse, id).Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
+	err := db.QueryRow(selectResponse, id).Scan(resp.fields()...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: response %v", ErrResponseNotFound, id)
+	}
 	if err != nil {
 		return nil, errors.New("error selecting response: " + err.Error())
 	}
@@ -146,121 +184,16 @@ func GetResponse(id int64, db *sql.DB) (*Response, error) {
 	if err != nil {
 		return nil, errors.New("error getting response options: " + err.Error())
 	}
-	resp.FormID, err = getElementFormID(resp.ElementID, db)
-	if err != nil {
-		return nil, errors.New("error getting response form id: " + err.Error())
-	}
-	return resp.ToResponse(), nil
-}
-
-func GetResponses(db *sql.DB) ([]*Response, error) {
-	selectResponses := "SELECT id, elementID, userID, value, createdAt, approved FROM responses"
-	rows, err := db.Query(selectResponses)
+	response := resp.ToResponse()
+	err = addReviewComments([]*Response{response}, db)
 	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning responses: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, db)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID, err = getElementFormID(resp.ElementID, db)
-		if err != nil {
-			return nil, errors.New("error getting response form id: " + err.Error())
-		}
-		fmt.Println("Form ID:", resp.FormID)
-		responses = append(responses, resp.ToResponse())
-	}
-	return responses, nil
-}
-
-func GetApprovedResponses(db *sql.DB) ([]*Response, error) {
-	selectResponses := "SELECT id, elementID, userID, value, createdAt, approved FROM responses WHERE approved = true"
-	rows, err := db.Query(selectResponses)
-	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning responses: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, db)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID, err = getElementFormID(resp.ElementID, db)
-		if err != nil {
-			return nil, errors.New("error getting response form id: " + err.Error())
-		}
-		responses = append(responses, resp.ToResponse())
-	}
-	return responses, nil
-}
-
-func GetApprovedResponsesByProvider(providerID int64, db *sql.DB) ([]*Response, error) {
-	selectResponses := "SELECT id, elementID, userID, value, createdAt, approved FROM responses WHERE approved = true AND userID = ?"
-	rows, err := db.Query(selectResponses, providerID)
-	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning responses: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, db)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID, err = getElementFormID(resp.ElementID, db)
-		if err != nil {
-			return nil, errors.New("error getting response form id: " + err.Error())
-		}
-		responses = append(responses, resp.ToResponse())
+		return nil, err
 	}
-	return responses, nil
-}
-
-func GetResponsesByProvider(providerID int64, db *sql.DB) ([]*Response, error) {
-	selectResponses := "SELECT id, elementID, userID, value, createdAt, approved FROM responses WHERE userID = ?"
-	rows, err := db.Query(selectResponses, providerID)
+	err = openResponses([]*Response{response}, keys)
 	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning responses: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, db)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID, err = getElementFormID(resp.ElementID, db)
-		if err != nil {
-			return nil, errors.New("error getting response form id: " + err.Error())
-		}
-		fmt.Println("Form ID:", resp.FormID)
-		responses = append(responses, resp.ToResponse())
+		return nil, err
 	}
-	return responses, nil
+	return response, nil
 }
 
 func getOptionsForResponse(responseID int64, db *sql.DB) ([]int64, error) {
@@ -282,29 +215,6 @@ func getOptionsForResponse(responseID int64, db *sql.DB) ([]int64, error) {
 	return optionIDs, nil
 }
 
-func getElementFormID(elementID int64, db *sql.DB) (int64, error) {
-	selectForm := "SELECT formID FROM elements WHERE id = ?"
-	var formID int64
-	err := db.QueryRow(selectForm, elementID).Scan(&formID)
-	if err != nil {
-		return 0, errors.New("error selecting form id: " + err.Error())
-	}
-	return formID, nil
-}
-
-func validateElement(elementID int64, db *sql.DB) error {
-	selectElement := "SELECT id FROM elements WHERE id = ?"
-	var selectedElement int64
-	err := db.QueryRow(selectElement, elementID).Scan(&selectedElement)
-	if err != nil {
-		return fmt.Errorf("error selecting element %v: %s", elementID, err.Error())
-	}
-	if selectedElement == 0 {
-		return fmt.Errorf("element %v not found", elementID)
-	}
-	return nil
-}
-
 func validateUser(userID int64, db *sql.DB) error {
 	user, err := users.Get(userID, db)
 	if err != nil {
@@ -362,87 +272,12 @@ func GetApprovedFormResponses(db *sql.DB) ([]*FormResponse, error) {
 	return responses, nil
 }
 
-func GetResponsesByForm(formID int64, db *sql.DB) ([]*Response, error) {
-	selectResponses := "SELECT id, elementID, userID, value, createdAt, approved FROM responses WHERE elementID IN (SELECT id FROM elements WHERE formID = ?)"
-	rows, err := db.Query(selectResponses, formID)
-	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning responses: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, db)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID = formID
-		responses = append(responses, resp.ToResponse())
-	}
-	return responses, nil
-}
-
-func GetApprovedResponsesByForm(formID int64, db *sql.DB) ([]*Response, error) {
-	selectResponses := "SELECT id, elementID, userID, value, createdAt, approved FROM responses WHERE approved = true AND elementID IN (SELECT id FROM elements WHERE formID = ?)"
-	rows, err := db.Query(selectResponses, formID)
-	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning responses: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, db)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID = formID
-		responses = append(responses, resp.ToResponse())
-	}
-	return responses, nil
-}
-
-func GetResponsesByFormAndToken(formID int64, token string, e *env.Env) ([]*Response, error) {
-	user, err := users.GetUserBySession(token, e)
-	if err != nil {
-		return nil, errors.New("error getting user: " + err.Error())
-	}
-	selectResponses := "SELECT r.id, r.elementID, r.userID, r.value, r.createdAt, r.approved FROM responses r, elements e WHERE r.elementID = e.id AND e.formID = ? AND r.userID = ?"
-	rows, err := e.DB.Query(selectResponses, formID, user.ID)
-	if err != nil {
-		return nil, errors.New("error selecting responses: " + err.Error())
-	}
-	defer rows.Close()
-	var responses []*Response
-	for rows.Next() {
-		var resp sqlResponse
-		err := rows.Scan(&resp.ID, &resp.ElementID, &resp.UserID, &resp.Value, &resp.CreatedAt, &resp.Approved)
-		if err != nil {
-			return nil, errors.New("error scanning response: " + err.Error())
-		}
-		resp.OptionIDs, err = getOptionsForResponse(resp.ID, e.DB)
-		if err != nil {
-			return nil, errors.New("error getting response options: " + err.Error())
-		}
-		resp.FormID = formID
-		responses = append(responses, resp.ToResponse())
-	}
-	return responses, nil
-}
-
-func ApproveResponse(id int64, approved bool, db *sql.DB) error {
-	updateResponse := "UPDATE responses SET approved = ? WHERE id = ?"
-	_, err := db.Exec(updateResponse, approved, id)
-	if err != nil {
-		return errors.New("error updating response: " + err.Error())
-	}
-	return nil
+// ApproveResponse approves or rejects a single response, see DecideResponses
+func ApproveResponse(id int64, approved bool, actorID int64, db *sql.DB) error {
+	status := ReviewRejected
+	if approved {
+		status = ReviewApproved
+	}
+	_, err := DecideResponses([]int64{id}, status, "", actorID, db)
+	return err
 }
@@ -2,6 +2,7 @@ package responses_test
 
 import (
 	"api/env"
+	"api/forms"
 	"api/forms/responses"
 	"api/users"
 	"errors"
@@ -21,7 +22,7 @@ func TestNewResponse(t *testing.T) {
 		return
 	}
 	value := "test"
-	response, err := responses.NewResponse(elementID, userID, value, e.DB)
+	response, err := responses.NewResponse(elementID, userID, value, e.Keys, e.DB)
 	if err != nil {
 		t.Error(err)
 		return
@@ -72,7 +73,7 @@ func TestNewResponseWithInvalidUserAgreement(t *testing.T) {
 	}
 
 	elementID := int64(1)
-	_, err = responses.NewResponse(elementID, userID, "test", e.DB)
+	_, err = responses.NewResponse(elementID, userID, "test", e.Keys, e.DB)
 	if err == nil {
 		t.Error("expected error when creating response with invalid user agreement")
 	}
@@ -96,7 +97,7 @@ func TestNewResponseWithInvalidElement(t *testing.T) {
 		return
 	}
 	value := "test"
-	_, err = responses.NewResponse(elementID, userID, value, e.DB)
+	_, err = responses.NewResponse(elementID, userID, value, e.Keys, e.DB)
 	if err == nil {
 		t.Error("Expected error when creating response with invalid element")
 	}
@@ -116,7 +117,7 @@ func TestNewResponseWithInvalidUser(t *testing.T) {
 	}
 	userID := maxUserID + 1000000
 	value := "test"
-	_, err = responses.NewResponse(elementID, userID, value, e.DB)
+	_, err = responses.NewResponse(elementID, userID, value, e.Keys, e.DB)
 	if err == nil {
 		t.Error("Expected error when creating response with invalid user")
 	}
@@ -222,7 +223,7 @@ func TestGetResponse(t *testing.T) {
 		t.Error("failed to get response ID: " + err.Error())
 		return
 	}
-	response, err := responses.GetResponse(responseID, e.DB)
+	response, err := responses.GetResponse(responseID, e.Keys, e.DB)
 	if err != nil {
 		t.Error("failed to get response: " + err.Error())
 		return
@@ -250,7 +251,7 @@ func TestGetResponseWithEmptyValue(t *testing.T) {
 		t.Error("failed to get response ID: " + err.Error())
 		return
 	}
-	response, err := responses.GetResponse(responseID, e.DB)
+	response, err := responses.GetResponse(responseID, e.Keys, e.DB)
 	if err != nil {
 		t.Error("failed to get response: " + err.Error())
 		return
@@ -269,7 +270,7 @@ func TestGetResponseWithOptions(t *testing.T) {
 		t.Error("failed to get response ID: " + err.Error())
 		return
 	}
-	response, err := responses.GetResponse(responseID, e.DB)
+	response, err := responses.GetResponse(responseID, e.Keys, e.DB)
 	if err != nil {
 		t.Error("failed to get response: " + err.Error())
 		return
@@ -281,19 +282,38 @@ func TestGetResponseWithOptions(t *testing.T) {
 
 func TestGetResponses(t *testing.T) {
 	e := env.TestSetup(t, true, pathToDotEnv)
-	resps, err := responses.GetResponses(e.DB)
+	page, err := responses.GetResponses(&responses.ResponseFilter{Limit: 1}, e.Keys, e.DB)
 	if err != nil {
 		t.Error("failed to get responses: " + err.Error())
 		return
 	}
-	if len(resps) == 0 {
-		t.Error("expected at least one response")
+	if len(page.Responses) != 1 {
+		t.Fatal("expected one response; got", len(page.Responses))
+	}
+	if page.NextCursor == "" {
+		return
+	}
+	next, err := responses.GetResponses(&responses.ResponseFilter{Limit: 1, Cursor: page.NextCursor}, e.Keys, e.DB)
+	if err != nil {
+		t.Error("failed to get next page of responses: " + err.Error())
+		return
+	}
+	if len(next.Responses) != 1 || next.Responses[0].ID <= page.Responses[0].ID {
+		t.Error("expected the next page to continue after response", page.Responses[0].ID)
+	}
+	_, err = responses.GetResponses(&responses.ResponseFilter{Cursor: "not a cursor"}, e.Keys, e.DB)
+	if !errors.Is(err, responses.ErrResponseQuery) {
+		t.Error("expected a malformed cursor to be rejected; got", err)
 	}
 }
 
 func TestGetFormResponsesByToken(t *testing.T) {
 	e := env.TestSetup(t, true, pathToDotEnv)
-	token := users.TestSessionToken
+	_, token, err := users.TestTokens(e)
+	if err != nil {
+		t.Error("failed to get test session token: " + err.Error())
+		return
+	}
 	responses, err := responses.GetFormResponsesByToken(token, e)
 	if err != nil {
 		t.Error("failed to get form responses: " + err.Error())
@@ -313,20 +333,25 @@ func TestGetFormResponsesByToken(t *testing.T) {
 func TestGetResponsesByForm(t *testing.T) {
 	e := env.TestSetup(t, true, pathToDotEnv)
 	formID := int64(1)
-	responses, err := responses.GetResponsesByFormAndToken(formID, users.TestSessionToken, e)
+	_, token, err := users.TestTokens(e)
 	if err != nil {
-		t.Error("failed to get responses: " + err.Error())
+		t.Error("failed to get test session token: " + err.Error())
 		return
 	}
-	if len(responses) == 0 {
-		t.Error("expected at least one response")
-	}
-	user, err := users.GetUserBySession(users.TestSessionToken, e)
+	user, err := users.GetUserBySession(token, e)
 	if err != nil {
 		t.Error("failed to get user: " + err.Error())
 		return
 	}
-	for _, response := range responses {
+	page, err := responses.GetResponses(&responses.ResponseFilter{FormID: formID, UserID: user.ID}, e.Keys, e.DB)
+	if err != nil {
+		t.Error("failed to get responses: " + err.Error())
+		return
+	}
+	if len(page.Responses) == 0 {
+		t.Error("expected at least one response")
+	}
+	for _, response := range page.Responses {
 		// check if any returned element IDs are not part of the form
 		selectFormID := "select formID from elements where id = ?"
 		var elementFormID int64
@@ -353,13 +378,13 @@ func TestResponseApproval(t *testing.T) {
 		t.Error("failed to get response ID: " + err.Error())
 		return
 	}
-	err = responses.ApproveResponse(responseID, true, e.DB)
+	err = responses.ApproveResponse(responseID, true, 0, e.DB)
 	if err != nil {
 		t.Error("failed to approve response: " + err.Error())
 		return
 	}
 	// validate that the response was approved
-	resp, err := responses.GetResponse(responseID, e.DB)
+	resp, err := responses.GetResponse(responseID, e.Keys, e.DB)
 	if err != nil {
 		t.Error("failed to get response: " + err.Error())
 	}
@@ -367,8 +392,204 @@ func TestResponseApproval(t *testing.T) {
 		t.Error("expected response to be approved")
 	}
 	// disapprove response
-	err = responses.ApproveResponse(responseID, false, e.DB)
+	err = responses.ApproveResponse(responseID, false, 0, e.DB)
 	if err != nil {
 		t.Error("failed to disapprove response: " + err.Error())
 	}
 }
+
+func TestGetProviderProfile(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	selectProvider := "select distinct u.id from users u, responses r where r.userID = u.id and r.approved = true and u.providerStatus = 'approved'"
+	var providerID int64
+	err := e.DB.QueryRow(selectProvider).Scan(&providerID)
+	if err != nil {
+		t.Error("failed to get provider ID: " + err.Error())
+		return
+	}
+	profile, err := responses.GetProviderProfile(providerID, forms.VisibilityPublic, e.DB)
+	if err != nil {
+		t.Error("failed to get provider profile: " + err.Error())
+		return
+	}
+	if profile.Provider.ID != providerID {
+		t.Error("expected provider ID to be", providerID, "; got", profile.Provider.ID)
+	}
+	if len(profile.Forms) == 0 {
+		t.Error("expected profile to have at least one form")
+	}
+	for _, form := range profile.Forms {
+		for i, answer := range form.Answers {
+			if answer.Label == "" {
+				t.Error("expected element", answer.ElementID, "to have a label")
+			}
+			if i > 0 && answer.Position < form.Answers[i-1].Position {
+				t.Error("expected answers in form", form.FormID, "to be ordered by position")
+			}
+		}
+	}
+}
+
+func TestModerationQueue(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	queue, err := responses.GetModerationQueue(&responses.ResponseFilter{}, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to get moderation queue: " + err.Error())
+	}
+	if len(queue.Groups) == 0 {
+		t.Skip("no responses waiting for review")
+	}
+	group := queue.Groups[0]
+	item := group.Responses[0]
+	if item.UserID != group.UserID || item.FormID != group.FormID || item.Label == "" {
+		t.Error("expected responses to be grouped by user and form with labels")
+	}
+	decisions, err := responses.DecideResponses([]int64{item.ID, item.ID}, responses.ReviewRejected, "needs a license number", 0, e.DB)
+	if err != nil {
+		t.Fatal("failed to decide response: " + err.Error())
+	}
+	if len(decisions) != 1 || decisions[0].Note != "needs a license number" || decisions[0].Approved {
+		t.Error("expected one rejection with the note; got", decisions)
+	}
+	queue, err = responses.GetModerationQueue(&responses.ResponseFilter{FormID: group.FormID, UserID: group.UserID}, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to get moderation queue: " + err.Error())
+	}
+	for _, group := range queue.Groups {
+		for _, other := range group.Responses {
+			if other.ID == item.ID {
+				t.Error("expected reviewed response to leave the queue")
+			}
+		}
+	}
+	_, err = responses.DecideResponses([]int64{item.ID, -1}, responses.ReviewApproved, "", 0, e.DB)
+	if !errors.Is(err, responses.ErrResponseNotFound) {
+		t.Error("expected unknown responses to be rejected; got", err)
+	}
+}
+
+func TestReviewThread(t *testing.T) {
+	e := env.TestSetup(t, true, pathToDotEnv)
+	queue, err := responses.GetModerationQueue(&responses.ResponseFilter{}, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to get moderation queue: " + err.Error())
+	}
+	if len(queue.Groups) == 0 {
+		t.Skip("no responses waiting for review")
+	}
+	item := queue.Groups[0].Responses[0]
+	_, err = responses.DecideResponses([]int64{item.ID}, responses.ReviewChangesRequested, "please add your license number", 0, e.DB)
+	if err != nil {
+		t.Fatal("failed to request changes: " + err.Error())
+	}
+	_, err = responses.AddComment(item.ID, item.UserID+1, false, "not mine", e.DB)
+	if !errors.Is(err, responses.ErrNotResponseOwner) {
+		t.Error("expected other users not to be able to comment; got", err)
+	}
+	_, err = responses.AddComment(item.ID, item.UserID, false, "which one?", e.DB)
+	if err != nil {
+		t.Fatal("failed to comment: " + err.Error())
+	}
+	resp, err := responses.ResubmitResponse(item.ID, item.UserID, "", e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to resubmit: " + err.Error())
+	}
+	if resp.Review.Status != responses.ReviewPending || resp.Review.ReviewedAt != nil {
+		t.Error("expected the resubmitted response to be pending; got", resp.Review)
+	}
+	comments := resp.Review.Comments
+	if len(comments) < 2 || comments[len(comments)-2].Status != responses.ReviewChangesRequested || comments[len(comments)-1].Body != "which one?" {
+		t.Error("expected the reviewer's note and the reply in the thread; got", comments)
+	}
+	_, err = responses.ResubmitResponse(item.ID, item.UserID, "", e.Keys, e.DB)
+	if !errors.Is(err, responses.ErrReview) {
+		t.Error("expected pending responses not to be resubmitted; got", err)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	e := env.TestSetup(t, false, pathToDotEnv)
+	userID, err := getTestUserID(e)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+	form, err := forms.NewForm(&forms.Form{
+		Name: "Draft form",
+		Live: true,
+		Elements: []*forms.Element{
+			{Label: "Name", Type: forms.TypeShortText, Position: 0, Required: true},
+			{Label: "City", Type: forms.TypeShortText, Position: 1},
+		},
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating form. " + err.Error())
+		return
+	}
+	defer forms.DeleteForm(form.ID, true, e.DB)
+	name, city := form.Elements[0].ID, form.Elements[1].ID
+
+	// first saves of the same draft don't overwrite each other
+	done := make(chan error, 2)
+	for _, answer := range []*responses.Answer{{ElementID: name, Value: "Sam"}, {ElementID: city, Value: "Denver"}} {
+		go func(answer *responses.Answer) {
+			_, err := responses.SaveDraft(form.ID, userID, []*responses.Answer{answer}, time.Hour, e.Keys, e.DB)
+			done <- err
+		}(answer)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Fatal("failed to save draft: " + err.Error())
+		}
+	}
+	draft, err := responses.GetDraft(form.ID, userID, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to get draft: " + err.Error())
+	}
+	if len(draft.Answers) != 2 {
+		t.Error("expected both saved answers; got", draft.Answers)
+	}
+
+	// an empty answer removes the element from the draft
+	draft, err = responses.SaveDraft(form.ID, userID, []*responses.Answer{{ElementID: city}}, time.Hour, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to save draft: " + err.Error())
+	}
+	if len(draft.Answers) != 1 || draft.Answers[0].ElementID != name {
+		t.Error("expected only the name to be left; got", draft.Answers)
+	}
+
+	resps, err := responses.SubmitDraft(form.ID, userID, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to submit draft: " + err.Error())
+	}
+	if len(resps) != 1 || resps[0].Value != "Sam" {
+		t.Error("expected the draft's answer to be submitted; got", resps)
+	}
+	_, err = responses.GetDraft(form.ID, userID, e.Keys, e.DB)
+	if !errors.Is(err, responses.ErrDraftNotFound) {
+		t.Error("expected the submitted draft to be deleted; got", err)
+	}
+
+	// expired drafts aren't returned and don't carry into a new draft
+	_, err = responses.SaveDraft(form.ID, userID, []*responses.Answer{{ElementID: city, Value: "Boulder"}}, time.Millisecond, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to save draft: " + err.Error())
+	}
+	time.Sleep(time.Second)
+	_, err = responses.GetDraft(form.ID, userID, e.Keys, e.DB)
+	if !errors.Is(err, responses.ErrDraftNotFound) {
+		t.Error("expected the expired draft to be gone; got", err)
+	}
+	draft, err = responses.SaveDraft(form.ID, userID, []*responses.Answer{{ElementID: name, Value: "Alex"}}, time.Hour, e.Keys, e.DB)
+	if err != nil {
+		t.Fatal("failed to save draft: " + err.Error())
+	}
+	if len(draft.Answers) != 1 || draft.Answers[0].Value != "Alex" {
+		t.Error("expected a new draft without the expired answers; got", draft.Answers)
+	}
+	err = responses.DeleteDraft(form.ID, userID, e.DB)
+	if err != nil {
+		t.Error("failed to delete draft: " + err.Error())
+	}
+}
@@ -0,0 +1,178 @@
+package responses
+
+import (
+	"api/encryption"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ReviewStatus is where a response is in review
+type ReviewStatus string
+
+const (
+	ReviewPending          ReviewStatus = "pending"
+	ReviewApproved         ReviewStatus = "approved"
+	ReviewRejected         ReviewStatus = "rejected"
+	ReviewChangesRequested ReviewStatus = "changes_requested" // the submitter is asked to edit and resubmit
+)
+
+// ErrReview is wrapped by errors for review comments that can't be added
+var ErrReview = errors.New("invalid review")
+
+// Review is where a response is in review, with the thread between reviewers and the
+// submitter. Decisions made with a note add it to the thread.
+type Review struct {
+	Status     ReviewStatus     `json:"status"`
+	ReviewedAt *time.Time       `json:"reviewed_at"` // when the status was last decided, nil while pending
+	Comments   []*ReviewComment `json:"comments"`
+}
+
+type ReviewComment struct {
+	ID         int64        `json:"id"`
+	ResponseID int64        `json:"response_id"`
+	AuthorID   int64        `json:"author_id"`
+	Body       string       `json:"body"`
+	Status     ReviewStatus `json:"status,omitempty"` // the decision the comment was made with, if any
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// validDecision reports whether a reviewer can move a response to status
+func validDecision(status ReviewStatus) bool {
+	return status == ReviewApproved || status == ReviewRejected || status == ReviewChangesRequested
+}
+
+func validReviewStatus(status ReviewStatus) bool {
+	return status == ReviewPending || validDecision(status)
+}
+
+func insertComment(comment *ReviewComment, tx *sql.Tx) error {
+	result, err := tx.Exec(
+		"insert into response_comments (responseID, authorID, body, status, createdAt) values (?, ?, ?, ?, ?)",
+		comment.ResponseID,
+		sql.NullInt64{Int64: comment.AuthorID, Valid: comment.AuthorID != 0},
+		comment.Body,
+		sql.NullString{String: string(comment.Status), Valid: comment.Status != ""},
+		comment.CreatedAt,
+	)
+	if err != nil {
+		return errors.New("error inserting review comment: " + err.Error())
+	}
+	comment.ID, err = result.LastInsertId()
+	if err != nil {
+		return errors.New("error getting review comment id: " + err.Error())
+	}
+	return nil
+}
+
+// AddComment adds a comment to a response's review thread. Reviewers can comment on any
+// response and everyone else only on their own.
+func AddComment(responseID int64, authorID int64, reviewer bool, body string, db *sql.DB) (*ReviewComment, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return nil, fmt.Errorf("%w: comments can't be empty", ErrReview)
+	}
+	comment := ReviewComment{ResponseID: responseID, AuthorID: authorID, Body: body, CreatedAt: time.Now()}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	var ownerID int64
+	err = tx.QueryRow("select userID from responses where id = ? for update", responseID).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: response %v", ErrResponseNotFound, responseID)
+	}
+	if err != nil {
+		return nil, errors.New("error selecting response: " + err.Error())
+	}
+	if !reviewer && ownerID != authorID {
+		return nil, fmt.Errorf("%w: response %v", ErrNotResponseOwner, responseID)
+	}
+	err = insertComment(&comment, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing review comment: " + err.Error())
+	}
+	return &comment, nil
+}
+
+// addReviewComments loads the review threads of all the responses in one query
+func addReviewComments(responses []*Response, db *sql.DB) error {
+	if len(responses) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Response, len(responses))
+	ids := make([]int64, len(responses))
+	for i, response := range responses {
+		byID[response.ID] = response
+		ids[i] = response.ID
+	}
+	placeholders, args := idPlaceholders(ids)
+	rows, err := db.Query("select id, responseID, authorID, body, status, createdAt from response_comments where responseID in ("+placeholders+") order by id", args...)
+	if err != nil {
+		return errors.New("error selecting review comments: " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var comment ReviewComment
+		var authorID sql.NullInt64
+		var status sql.NullString
+		err := rows.Scan(&comment.ID, &comment.ResponseID, &authorID, &comment.Body, &status, &comment.CreatedAt)
+		if err != nil {
+			return errors.New("error scanning review comment: " + err.Error())
+		}
+		comment.AuthorID = authorID.Int64
+		comment.Status = ReviewStatus(status.String)
+		review := byID[comment.ResponseID].Review
+		review.Comments = append(review.Comments, &comment)
+	}
+	return nil
+}
+
+// ResubmitResponse sends a response that was rejected or sent back for changes to review again,
+// with an optional comment from the submitter. Editing the answer (see UpdateResponse) also
+// resubmits it.
+func ResubmitResponse(id int64, userID int64, body string, keys *encryption.Keyring, db *sql.DB) (*Response, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	var ownerID int64
+	var status ReviewStatus
+	err = tx.QueryRow("select userID, reviewStatus from responses where id = ? for update", id).Scan(&ownerID, &status)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: response %v", ErrResponseNotFound, id)
+	}
+	if err != nil {
+		return nil, errors.New("error selecting response: " + err.Error())
+	}
+	if ownerID != userID {
+		return nil, fmt.Errorf("%w: response %v", ErrNotResponseOwner, id)
+	}
+	if status != ReviewRejected && status != ReviewChangesRequested {
+		return nil, fmt.Errorf("%w: only rejected responses and responses with changes requested can be resubmitted", ErrReview)
+	}
+	_, err = tx.Exec("update responses set reviewStatus = ?, approved = false, reviewedAt = NULL where id = ?", ReviewPending, id)
+	if err != nil {
+		return nil, errors.New("error updating response: " + err.Error())
+	}
+	body = strings.TrimSpace(body)
+	if body != "" {
+		err = insertComment(&ReviewComment{ResponseID: id, AuthorID: userID, Body: body, Status: ReviewPending, CreatedAt: time.Now()}, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing resubmission: " + err.Error())
+	}
+	return GetResponse(id, keys, db)
+}
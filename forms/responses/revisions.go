@@ -0,0 +1,274 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrResponseNotFound is returned for responses that don't exist
+	ErrResponseNotFound = errors.New("response not found")
+	// ErrNotResponseOwner is returned when a user tries to change someone else's response
+	ErrNotResponseOwner = errors.New("user does not own response")
+)
+
+// ResponseRevision is a response's answer before it was edited
+type ResponseRevision struct {
+	ID         int64     `json:"id"`
+	ResponseID int64     `json:"response_id"`
+	Value      string    `json:"value"`
+	OptionIDs  []int64   `json:"option_ids"`
+	Approved   bool      `json:"approved"` // whether the answer had been approved before it was edited
+	RevisedBy  int64     `json:"revised_by"`
+	RevisedAt  time.Time `json:"revised_at"`
+}
+
+// ResponseChange is what one edit changed about a response
+type ResponseChange struct {
+	RevisedAt      time.Time `json:"revised_at"`
+	RevisedBy      int64     `json:"revised_by"`
+	WasApproved    bool      `json:"was_approved"`
+	PreviousValue  string    `json:"previous_value"`
+	Value          string    `json:"value"`
+	AddedOptions   []string  `json:"added_options"`
+	RemovedOptions []string  `json:"removed_options"`
+}
+
+// ResponseHistory is a response with every change made to it, oldest first
+type ResponseHistory struct {
+	Response *Response         `json:"response"`
+	Changes  []*ResponseChange `json:"changes"`
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, other := range ids {
+		if other == id {
+			return true
+		}
+	}
+	return false
+}
+
+func sameOptions(a []int64, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, id := range b {
+		if !containsID(a, id) {
+			return false
+		}
+	}
+	return true
+}
+
+// UpdateResponse changes the answer of one of the user's responses. The previous answer is kept
+// as a revision, and a changed answer goes back to pending review.
+func UpdateResponse(id int64, userID int64, value string, optionIDs []int64, keys *encryption.Keyring, db *sql.DB) (*Response, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	var current sqlResponse
+	err = tx.QueryRow("SELECT id, elementID, userID, value, approved FROM responses WHERE id = ? FOR UPDATE", id).Scan(&current.ID, &current.ElementID, &current.UserID, &current.Value, &current.Approved)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: response %v", ErrResponseNotFound, id)
+	}
+	if err != nil {
+		return nil, errors.New("error selecting response: " + err.Error())
+	}
+	if current.UserID != userID {
+		return nil, fmt.Errorf("%w: response %v", ErrNotResponseOwner, id)
+	}
+	element, err := forms.GetElement(current.ElementID, db)
+	if err != nil {
+		return nil, err
+	}
+	value, err = element.ValidateAnswer(value, optionIDs)
+	if err != nil {
+		return nil, err
+	}
+	if value == "" && len(optionIDs) == 0 {
+		return nil, &forms.AnswerError{Message: "an answer is required"}
+	}
+	currentOptions, err := getOptionsForResponse(id, db)
+	if err != nil {
+		return nil, errors.New("error getting response options: " + err.Error())
+	}
+	currentValue, err := openValue(current.Value.String, keys)
+	if err != nil {
+		return nil, err
+	}
+	if value == currentValue && sameOptions(optionIDs, currentOptions) {
+		return GetResponse(id, keys, db)
+	}
+	sensitive, err := elementSensitive(current.ElementID, tx)
+	if err != nil {
+		return nil, err
+	}
+	stored := value
+	if sensitive && value != "" {
+		stored, err = keys.Seal(value)
+		if err != nil {
+			return nil, errors.New("error sealing answer: " + err.Error())
+		}
+	}
+
+	previousOptions, err := json.Marshal(currentOptions)
+	if err != nil {
+		return nil, errors.New("error encoding response options: " + err.Error())
+	}
+	_, err = tx.Exec(
+		"INSERT INTO response_revisions (responseID, value, optionIDs, approved, revisedBy, revisedAt) VALUES (?, ?, ?, ?, ?, ?)",
+		id, current.Value, string(previousOptions), current.Approved, userID, time.Now(),
+	)
+	if err != nil {
+		return nil, errors.New("error inserting response revision: " + err.Error())
+	}
+	// the revision keeps the previous value as it was stored, sealed or not
+	_, err = tx.Exec("UPDATE responses SET value = ?, approved = false, reviewStatus = ?, reviewedAt = NULL WHERE id = ?", sql.NullString{String: stored, Valid: !element.IsSelect()}, ReviewPending, id)
+	if err != nil {
+		return nil, errors.New("error updating response: " + err.Error())
+	}
+	_, err = tx.Exec("DELETE FROM response_options WHERE responseID = ?", id)
+	if err != nil {
+		return nil, errors.New("error deleting response options: " + err.Error())
+	}
+	for _, optionID := range optionIDs {
+		_, err = tx.Exec("INSERT INTO response_options (responseID, optionID) VALUES (?, ?)", id, optionID)
+		if err != nil {
+			return nil, errors.New("error inserting response options: " + err.Error())
+		}
+	}
+	if current.Approved {
+		// the edited answer isn't approved any more, so it comes out of provider search
+		err = reindexResponses([]int64{id}, tx)
+		if err != nil {
+			return nil, errors.New("error indexing response: " + err.Error())
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing response: " + err.Error())
+	}
+	return GetResponse(id, keys, db)
+}
+
+func getResponseRevisions(responseID int64, keys *encryption.Keyring, db *sql.DB) ([]*ResponseRevision, error) {
+	rows, err := db.Query("SELECT id, responseID, value, optionIDs, approved, revisedBy, revisedAt FROM response_revisions WHERE responseID = ? ORDER BY id", responseID)
+	if err != nil {
+		return nil, errors.New("error selecting response revisions: " + err.Error())
+	}
+	defer rows.Close()
+	revisions := []*ResponseRevision{}
+	for rows.Next() {
+		var revision ResponseRevision
+		var value sql.NullString
+		var optionIDs string
+		err := rows.Scan(&revision.ID, &revision.ResponseID, &value, &optionIDs, &revision.Approved, &revision.RevisedBy, &revision.RevisedAt)
+		if err != nil {
+			return nil, errors.New("error scanning response revision: " + err.Error())
+		}
+		revision.Value, err = openValue(value.String, keys)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(optionIDs), &revision.OptionIDs)
+		if err != nil {
+			return nil, errors.New("error decoding response revision options: " + err.Error())
+		}
+		revisions = append(revisions, &revision)
+	}
+	return revisions, nil
+}
+
+func getOptionNames(optionIDs []int64, db *sql.DB) (map[int64]string, error) {
+	names := map[int64]string{}
+	if len(optionIDs) == 0 {
+		return names, nil
+	}
+	placeholders := make([]string, len(optionIDs))
+	args := make([]interface{}, len(optionIDs))
+	for i, id := range optionIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	rows, err := db.Query("SELECT id, name FROM options WHERE id IN ("+strings.Join(placeholders, ", ")+")", args...)
+	if err != nil {
+		return nil, errors.New("error selecting option names: " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var name string
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return nil, errors.New("error scanning option name: " + err.Error())
+		}
+		names[id] = name
+	}
+	return names, nil
+}
+
+// responseChanges compares each revision with the answer that replaced it, ending with the
+// response's current answer
+func responseChanges(revisions []*ResponseRevision, current *Response, optionNames map[int64]string) []*ResponseChange {
+	changes := []*ResponseChange{}
+	for i, revision := range revisions {
+		next := ResponseRevision{Value: current.Value, OptionIDs: current.OptionIDs}
+		if i+1 < len(revisions) {
+			next = *revisions[i+1]
+		}
+		change := ResponseChange{
+			RevisedAt:      revision.RevisedAt,
+			RevisedBy:      revision.RevisedBy,
+			WasApproved:    revision.Approved,
+			PreviousValue:  revision.Value,
+			Value:          next.Value,
+			AddedOptions:   []string{},
+			RemovedOptions: []string{},
+		}
+		for _, id := range next.OptionIDs {
+			if !containsID(revision.OptionIDs, id) {
+				change.AddedOptions = append(change.AddedOptions, optionNames[id])
+			}
+		}
+		for _, id := range revision.OptionIDs {
+			if !containsID(next.OptionIDs, id) {
+				change.RemovedOptions = append(change.RemovedOptions, optionNames[id])
+			}
+		}
+		sort.Strings(change.AddedOptions)
+		sort.Strings(change.RemovedOptions)
+		changes = append(changes, &change)
+	}
+	return changes
+}
+
+// GetResponseHistory returns a response with what each edit to it changed, with values opened with keys
+func GetResponseHistory(id int64, keys *encryption.Keyring, db *sql.DB) (*ResponseHistory, error) {
+	response, err := GetResponse(id, keys, db)
+	if err != nil {
+		return nil, err
+	}
+	revisions, err := getResponseRevisions(id, keys, db)
+	if err != nil {
+		return nil, err
+	}
+	optionIDs := append([]int64{}, response.OptionIDs...)
+	for _, revision := range revisions {
+		optionIDs = append(optionIDs, revision.OptionIDs...)
+	}
+	optionNames, err := getOptionNames(optionIDs, db)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponseHistory{Response: response, Changes: responseChanges(revisions, response, optionNames)}, nil
+}
@@ -0,0 +1,32 @@
+package responses
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResponseChanges(t *testing.T) {
+	names := map[int64]string{1: "English", 2: "Spanish", 3: "Vietnamese"}
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	revisions := []*ResponseRevision{
+		{ID: 1, OptionIDs: []int64{1}, Approved: true, RevisedBy: 7, RevisedAt: first},
+		{ID: 2, OptionIDs: []int64{1, 2}, RevisedBy: 7, RevisedAt: first.Add(time.Hour)},
+	}
+	current := &Response{OptionIDs: []int64{2, 3}}
+	changes := responseChanges(revisions, current, names)
+	if len(changes) != 2 {
+		t.Error("expected a change for each revision; got", len(changes))
+		return
+	}
+	if !changes[0].WasApproved || len(changes[0].AddedOptions) != 1 || changes[0].AddedOptions[0] != "Spanish" || len(changes[0].RemovedOptions) != 0 {
+		t.Error("expected the first edit to add Spanish to an approved answer; got", changes[0])
+	}
+	if len(changes[1].AddedOptions) != 1 || changes[1].AddedOptions[0] != "Vietnamese" || len(changes[1].RemovedOptions) != 1 || changes[1].RemovedOptions[0] != "English" {
+		t.Error("expected the second edit to swap English for Vietnamese; got", changes[1])
+	}
+
+	changes = responseChanges([]*ResponseRevision{{Value: "Dr Smith"}}, &Response{Value: "Dr. Smith"}, names)
+	if changes[0].PreviousValue != "Dr Smith" || changes[0].Value != "Dr. Smith" {
+		t.Error("expected the value change to be shown; got", changes[0])
+	}
+}
@@ -0,0 +1,242 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Answers to elements that are sensitive in the current version of their form are stored
+// sealed with the keyring. They are only opened on reads by the user who gave them and by
+// admins; public outputs leave sensitive elements out.
+
+// sensitiveNow is true for responses r to elements e that are sensitive in the current version of the form
+const sensitiveNow = "exists (select 1 from elements se, forms sf where se.formID = sf.id and se.versionID = sf.currentVersionID and se.originID = e.originID and se.sensitive = true)"
+
+// sealAnswer seals the value of an answer to a sensitive element
+func sealAnswer(element *forms.Element, value string, keys *encryption.Keyring) (string, error) {
+	if !element.Sensitive || value == "" {
+		return value, nil
+	}
+	sealed, err := keys.Seal(value)
+	if err != nil {
+		return "", errors.New("error sealing answer: " + err.Error())
+	}
+	return sealed, nil
+}
+
+// openValue opens a stored value. Without keys, sealed values are left out.
+func openValue(value string, keys *encryption.Keyring) (string, error) {
+	if keys == nil && encryption.IsSealed(value) {
+		return "", nil
+	}
+	opened, err := keys.Open(value)
+	if err != nil {
+		return "", errors.New("error opening answer: " + err.Error())
+	}
+	return opened, nil
+}
+
+// openResponses opens the values of responses read from the database, see openValue
+func openResponses(responses []*Response, keys *encryption.Keyring) error {
+	for _, response := range responses {
+		value, err := openValue(response.Value, keys)
+		if err != nil {
+			return err
+		}
+		response.Value = value
+	}
+	return nil
+}
+
+// elementSensitive reports whether an element is sensitive in the current version of its form
+func elementSensitive(elementID int64, db forms.Querier) (bool, error) {
+	var sensitive bool
+	err := db.QueryRow("select exists (select 1 from elements e where e.id = ? and "+sensitiveNow+")", elementID).Scan(&sensitive)
+	if err != nil {
+		return false, errors.New("error checking element sensitivity: " + err.Error())
+	}
+	return sensitive, nil
+}
+
+// ReencryptResult counts the stored values Reencrypt changed
+type ReencryptResult struct {
+	Responses int `json:"responses"`
+	Revisions int `json:"revisions"`
+	Drafts    int `json:"drafts"`
+}
+
+const reencryptBatch = 500
+
+// Reencrypt brings every stored answer in line with the keyring and the current sensitivity of
+// its element: sensitive values are resealed with the current key and values of elements that
+// are no longer sensitive are decrypted. Run it after adding a key, before removing the old one.
+// Rows are updated one at a time and only if they haven't changed since they were read, so it
+// can run while the API is serving requests and be run again if it is interrupted.
+func Reencrypt(keys *encryption.Keyring, db *sql.DB) (*ReencryptResult, error) {
+	result := ReencryptResult{}
+	var err error
+	result.Responses, err = reencryptRows(
+		"select r.id, r.value, "+sensitiveNow+" from responses r, elements e where r.elementID = e.id and r.value is not null and r.id > ? order by r.id limit ?",
+		"update responses set value = ? where id = ? and value = ?",
+		keys, db,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result.Revisions, err = reencryptRows(
+		"select v.id, v.value, "+sensitiveNow+" from response_revisions v, responses r, elements e where v.responseID = r.id and r.elementID = e.id and v.value is not null and v.id > ? order by v.id limit ?",
+		"update response_revisions set value = ? where id = ? and value = ?",
+		keys, db,
+	)
+	if err != nil {
+		return nil, err
+	}
+	result.Drafts, err = reencryptDrafts(keys, db)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// reencryptRows reseals the values selected in batches by selectRows, which takes the last ID
+// seen and a limit and returns ID, value and whether the value is sensitive
+func reencryptRows(selectRows string, updateRow string, keys *encryption.Keyring, db *sql.DB) (int, error) {
+	type storedValue struct {
+		id        int64
+		value     string
+		sensitive bool
+	}
+	changed := 0
+	var lastID int64
+	for {
+		rows, err := db.Query(selectRows, lastID, reencryptBatch)
+		if err != nil {
+			return changed, errors.New("error selecting values to reencrypt: " + err.Error())
+		}
+		var batch []storedValue
+		for rows.Next() {
+			var stored storedValue
+			err := rows.Scan(&stored.id, &stored.value, &stored.sensitive)
+			if err != nil {
+				rows.Close()
+				return changed, errors.New("error scanning value to reencrypt: " + err.Error())
+			}
+			batch = append(batch, stored)
+		}
+		rows.Close()
+		if len(batch) == 0 {
+			return changed, nil
+		}
+		for _, stored := range batch {
+			value, resealed, err := keys.Reseal(stored.value, stored.sensitive)
+			if err != nil {
+				return changed, errors.New("error reencrypting value: " + err.Error())
+			}
+			if !resealed {
+				continue
+			}
+			result, err := db.Exec(updateRow, value, stored.id, stored.value)
+			if err != nil {
+				return changed, errors.New("error updating reencrypted value: " + err.Error())
+			}
+			updated, err := result.RowsAffected()
+			if err != nil {
+				return changed, errors.New("error updating reencrypted value: " + err.Error())
+			}
+			changed += int(updated)
+		}
+		lastID = batch[len(batch)-1].id
+	}
+}
+
+// reencryptDrafts reseals draft answers, which are stored by element origin
+func reencryptDrafts(keys *encryption.Keyring, db *sql.DB) (int, error) {
+	type storedDraft struct {
+		id        int64
+		formID    int64
+		answers   string
+		updatedAt time.Time
+	}
+	rows, err := db.Query("select id, formID, answers, updatedAt from response_drafts")
+	if err != nil {
+		return 0, errors.New("error selecting drafts to reencrypt: " + err.Error())
+	}
+	var drafts []storedDraft
+	for rows.Next() {
+		var draft storedDraft
+		err := rows.Scan(&draft.id, &draft.formID, &draft.answers, &draft.updatedAt)
+		if err != nil {
+			rows.Close()
+			return 0, errors.New("error scanning draft to reencrypt: " + err.Error())
+		}
+		drafts = append(drafts, draft)
+	}
+	rows.Close()
+
+	sensitive := map[int64]map[int64]bool{} // element origins by form
+	changed := 0
+	for _, draft := range drafts {
+		if _, ok := sensitive[draft.formID]; !ok {
+			origins, err := sensitiveOrigins(draft.formID, db)
+			if err != nil {
+				return changed, err
+			}
+			sensitive[draft.formID] = origins
+		}
+		var answers []*draftAnswer
+		err := json.Unmarshal([]byte(draft.answers), &answers)
+		if err != nil {
+			return changed, errors.New("error decoding draft: " + err.Error())
+		}
+		draftChanged := false
+		for _, answer := range answers {
+			value, resealed, err := keys.Reseal(answer.Value, sensitive[draft.formID][answer.ElementID])
+			if err != nil {
+				return changed, errors.New("error reencrypting draft: " + err.Error())
+			}
+			answer.Value = value
+			draftChanged = draftChanged || resealed
+		}
+		if !draftChanged {
+			continue
+		}
+		data, err := json.Marshal(answers)
+		if err != nil {
+			return changed, errors.New("error encoding draft: " + err.Error())
+		}
+		// drafts saved since they were read are left for the next run
+		result, err := db.Exec("update response_drafts set answers = ? where id = ? and updatedAt = ?", string(data), draft.id, draft.updatedAt)
+		if err != nil {
+			return changed, errors.New("error updating reencrypted draft: " + err.Error())
+		}
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return changed, errors.New("error updating reencrypted draft: " + err.Error())
+		}
+		changed += int(updated)
+	}
+	return changed, nil
+}
+
+// sensitiveOrigins returns the origins of the elements that are sensitive in the current version of a form
+func sensitiveOrigins(formID int64, db *sql.DB) (map[int64]bool, error) {
+	rows, err := db.Query("select e.originID from elements e, forms f where e.formID = f.id and e.versionID = f.currentVersionID and f.id = ? and e.sensitive = true", formID)
+	if err != nil {
+		return nil, errors.New("error selecting sensitive elements: " + err.Error())
+	}
+	defer rows.Close()
+	origins := map[int64]bool{}
+	for rows.Next() {
+		var originID int64
+		err := rows.Scan(&originID)
+		if err != nil {
+			return nil, errors.New("error scanning sensitive element: " + err.Error())
+		}
+		origins[originID] = true
+	}
+	return origins, nil
+}
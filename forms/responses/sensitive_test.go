@@ -0,0 +1,34 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"testing"
+)
+
+func TestSealAnswer(t *testing.T) {
+	keys, err := encryption.ParseKeyring("test:AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	if err != nil {
+		t.Fatal("failed to parse keyring: " + err.Error())
+	}
+	plain, err := sealAnswer(&forms.Element{Type: forms.TypeShortText}, "Denver", keys)
+	if err != nil || plain != "Denver" {
+		t.Error("expected answers to elements that aren't sensitive to be stored as they are; got", plain, err)
+	}
+	sealed, err := sealAnswer(&forms.Element{Type: forms.TypeShortText, Sensitive: true}, "123 Main St", keys)
+	if err != nil || !encryption.IsSealed(sealed) {
+		t.Fatal("expected answers to sensitive elements to be sealed; got", sealed, err)
+	}
+	opened, err := openValue(sealed, keys)
+	if err != nil || opened != "123 Main St" {
+		t.Error("expected the answer back; got", opened, err)
+	}
+	opened, err = openValue(sealed, nil)
+	if err != nil || opened != "" {
+		t.Error("expected sealed answers to be left out without keys; got", opened, err)
+	}
+	_, err = sealAnswer(&forms.Element{Type: forms.TypeShortText, Sensitive: true}, "123 Main St", nil)
+	if err == nil {
+		t.Error("expected sealing without keys to fail")
+	}
+}
@@ -0,0 +1,192 @@
+package responses
+
+import (
+	"api/encryption"
+	"api/forms"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Answer is a single element's answer in a form submission
+type Answer struct {
+	ElementID int64   `json:"element_id"`
+	Value     string  `json:"value"`
+	OptionIDs []int64 `json:"option_ids"`
+}
+
+type Submission struct {
+	Answers []*Answer `json:"answers"`
+}
+
+// ValidationErrors maps element IDs to the problem with their answer
+type ValidationErrors map[int64]string
+
+func (v ValidationErrors) Error() string {
+	return fmt.Sprintf("%d invalid answers", len(v))
+}
+
+// validateSubmission checks every answer against the form's elements and options, and that
+// every required element is answered. Elements hidden by the form's conditions don't need
+// an answer and can't have one. Valid values are replaced with their normalized form.
+func validateSubmission(form *forms.Form, answers []*Answer) ValidationErrors {
+	return validateAnswers(form, form.Elements, answers, nil)
+}
+
+// validateAnswers is validateSubmission for answers to some of a form's elements. Conditions
+// on other elements are evaluated against previous answers, keyed by element ID.
+func validateAnswers(form *forms.Form, elementsAnswered []*forms.Element, answers []*Answer, previous map[int64]*forms.GivenAnswer) ValidationErrors {
+	invalid := ValidationErrors{}
+	elements := map[int64]*forms.Element{}
+	for _, element := range elementsAnswered {
+		elements[element.ID] = element
+	}
+	seen := map[int64]bool{}
+	answered := map[int64]bool{}
+	given := map[int64]*forms.GivenAnswer{}
+	for elementID, answer := range previous {
+		if _, ok := elements[elementID]; !ok {
+			given[elementID] = answer
+		}
+	}
+	for _, answer := range answers {
+		element, ok := elements[answer.ElementID]
+		if !ok {
+			invalid[answer.ElementID] = "element is not part of this form"
+			if len(elementsAnswered) < len(form.Elements) {
+				invalid[answer.ElementID] = "element is not part of this section"
+			}
+			continue
+		}
+		if seen[answer.ElementID] {
+			invalid[answer.ElementID] = "element is answered more than once"
+			continue
+		}
+		seen[answer.ElementID] = true
+		value, err := element.ValidateAnswer(answer.Value, answer.OptionIDs)
+		if err != nil {
+			invalid[answer.ElementID] = err.Error()
+			continue
+		}
+		answer.Value = value
+		// an empty answer is the same as no answer
+		answered[answer.ElementID] = !answerIsEmpty(answer)
+		given[answer.ElementID] = &forms.GivenAnswer{Value: answer.Value, OptionIDs: answer.OptionIDs}
+	}
+	visible := form.VisibleElements(given)
+	for _, element := range elementsAnswered {
+		if !visible[element.ID] {
+			if answered[element.ID] {
+				invalid[element.ID] = "this question is hidden by your other answers"
+			}
+			continue
+		}
+		if element.Required && !answered[element.ID] {
+			if _, ok := invalid[element.ID]; !ok {
+				invalid[element.ID] = "an answer is required"
+			}
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+	return invalid
+}
+
+func answerIsEmpty(answer *Answer) bool {
+	return strings.TrimSpace(answer.Value) == "" && len(answer.OptionIDs) == 0
+}
+
+// SubmitForm validates and saves all of a user's answers to a live form in one transaction.
+// If any answer is invalid nothing is saved and the error is ValidationErrors.
+func SubmitForm(formID int64, userID int64, submission *Submission, keys *encryption.Keyring, db *sql.DB) ([]*Response, error) {
+	err := validateUser(userID, db)
+	if err != nil {
+		return nil, err
+	}
+	form, err := forms.GetForm(formID, true, db)
+	if err != nil {
+		return nil, err
+	}
+	invalid := validateSubmission(form, submission.Answers)
+	if invalid != nil {
+		return nil, invalid
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	createdAt := time.Now()
+	saved, err := insertAnswers(form, userID, submission.Answers, createdAt, keys, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = saveProgress(form, form.Sections, userID, createdAt, tx)
+	if err != nil {
+		return nil, err
+	}
+	// the submission replaces any draft the user had saved
+	_, err = tx.Exec("delete from response_drafts where userID = ? and formID = ?", userID, formID)
+	if err != nil {
+		return nil, errors.New("error deleting draft: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing submission: " + err.Error())
+	}
+	return saved, nil
+}
+
+// insertAnswers saves the non-empty answers to a form as responses to its current version
+func insertAnswers(form *forms.Form, userID int64, answers []*Answer, createdAt time.Time, keys *encryption.Keyring, tx *sql.Tx) ([]*Response, error) {
+	elements := map[int64]*forms.Element{}
+	for _, element := range form.Elements {
+		elements[element.ID] = element
+	}
+	saved := []*Response{}
+	for _, answer := range answers {
+		if answerIsEmpty(answer) {
+			continue
+		}
+		resp := &Response{
+			FormID:      form.ID,
+			ElementID:   answer.ElementID,
+			UserID:      userID,
+			Value:       answer.Value,
+			OptionIDs:   answer.OptionIDs,
+			CreatedAt:   createdAt,
+			FormVersion: form.Version,
+		}
+		var result sql.Result
+		var err error
+		if len(answer.OptionIDs) > 0 {
+			result, err = tx.Exec("INSERT INTO responses (elementID, userID, createdAt, formVersion) VALUES (?, ?, ?, ?)", resp.ElementID, userID, createdAt, form.Version)
+		} else {
+			var value string
+			value, err = sealAnswer(elements[answer.ElementID], resp.Value, keys)
+			if err != nil {
+				return nil, err
+			}
+			result, err = tx.Exec("INSERT INTO responses (elementID, userID, value, createdAt, formVersion) VALUES (?, ?, ?, ?, ?)", resp.ElementID, userID, value, createdAt, form.Version)
+		}
+		if err != nil {
+			return nil, errors.New("error inserting response: " + err.Error())
+		}
+		resp.ID, err = result.LastInsertId()
+		if err != nil {
+			return nil, errors.New("error getting last insert id: " + err.Error())
+		}
+		for _, optionID := range answer.OptionIDs {
+			_, err = tx.Exec("INSERT INTO response_options (responseID, optionID) VALUES (?, ?)", resp.ID, optionID)
+			if err != nil {
+				return nil, errors.New("error inserting response options: " + err.Error())
+			}
+		}
+		saved = append(saved, resp)
+	}
+	return saved, nil
+}
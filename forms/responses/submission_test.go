@@ -0,0 +1,114 @@
+package responses
+
+import (
+	"api/forms"
+	"testing"
+)
+
+func TestValidateSubmission(t *testing.T) {
+	form := &forms.Form{
+		ID: 1,
+		Elements: []*forms.Element{
+			{ID: 1, Label: "Name", Type: forms.TypeShortText, Required: true},
+			{ID: 2, Label: "Languages", Type: forms.TypeMultiSelect, Options: []*forms.Option{{ID: 10, Name: "English"}, {ID: 11, Name: "Spanish"}}},
+			{ID: 3, Label: "Notes", Type: forms.TypeLongText},
+		},
+	}
+	testCases := []struct {
+		name    string
+		answers []*Answer
+		invalid []int64
+	}{
+		{"valid", []*Answer{{ElementID: 1, Value: "Sam"}, {ElementID: 2, OptionIDs: []int64{11}}}, nil},
+		{"missing required", []*Answer{{ElementID: 3, Value: "hello"}}, []int64{1}},
+		{"blank required", []*Answer{{ElementID: 1, Value: "  "}}, []int64{1}},
+		{"unknown element", []*Answer{{ElementID: 1, Value: "Sam"}, {ElementID: 99, Value: "x"}}, []int64{99}},
+		{"duplicate", []*Answer{{ElementID: 1, Value: "Sam"}, {ElementID: 1, Value: "Alex"}}, []int64{1}},
+		{"option from another element", []*Answer{{ElementID: 1, Value: "Sam"}, {ElementID: 2, OptionIDs: []int64{12}}}, []int64{2}},
+		{"value for options", []*Answer{{ElementID: 1, Value: "Sam"}, {ElementID: 2, Value: "Spanish"}}, []int64{2}},
+		{"options for value", []*Answer{{ElementID: 1, OptionIDs: []int64{10}}}, []int64{1}},
+	}
+	for _, tc := range testCases {
+		invalid := validateSubmission(form, tc.answers)
+		if len(invalid) != len(tc.invalid) {
+			t.Error(tc.name+": expected invalid elements", tc.invalid, "; got", invalid)
+			continue
+		}
+		for _, elementID := range tc.invalid {
+			if _, ok := invalid[elementID]; !ok {
+				t.Error(tc.name+": expected element", elementID, "to be invalid; got", invalid)
+			}
+		}
+	}
+}
+
+func TestValidateSubmissionConditions(t *testing.T) {
+	form := &forms.Form{
+		ID: 1,
+		Elements: []*forms.Element{
+			{ID: 1, OriginID: 1, Position: 0, Label: "Role", Type: forms.TypeSingleSelect, Required: true, Options: []*forms.Option{{ID: 10, OriginID: 10, Name: "Therapist"}, {ID: 11, OriginID: 11, Name: "Doula"}}},
+			{ID: 2, OriginID: 2, Position: 1, Label: "License type", Type: forms.TypeShortText, Required: true, ShowIf: &forms.ConditionGroup{
+				Conditions: []*forms.Condition{{ElementID: 1, Operator: forms.OpEquals, OptionID: 10}},
+			}},
+			{ID: 3, OriginID: 3, Position: 2, Label: "License number", Type: forms.TypeShortText, Required: true, ShowIf: &forms.ConditionGroup{
+				Conditions: []*forms.Condition{{ElementID: 2, Operator: forms.OpNotEmpty}},
+			}},
+			{ID: 4, OriginID: 4, Position: 3, Label: "Bio", Type: forms.TypeLongText},
+			{ID: 5, OriginID: 5, Position: 4, Label: "Training", Type: forms.TypeShortText, Required: true, ShowIf: &forms.ConditionGroup{
+				Match:      forms.MatchAny,
+				Conditions: []*forms.Condition{{ElementID: 1, Operator: forms.OpEquals, OptionID: 11}},
+				Groups: []*forms.ConditionGroup{{Conditions: []*forms.Condition{
+					{ElementID: 2, Operator: forms.OpIncludes, Value: "lpc"},
+					{ElementID: 4, Operator: forms.OpNotEmpty},
+				}}},
+			}},
+		},
+	}
+	testCases := []struct {
+		name    string
+		answers []*Answer
+		invalid []int64
+	}{
+		{"hidden required elements are skipped", []*Answer{{ElementID: 1, OptionIDs: []int64{11}}, {ElementID: 5, Value: "DONA"}}, nil},
+		{"shown required elements are checked", []*Answer{{ElementID: 1, OptionIDs: []int64{10}}}, []int64{2}},
+		{"chained conditions", []*Answer{{ElementID: 1, OptionIDs: []int64{10}}, {ElementID: 2, Value: "LCSW"}}, []int64{3}},
+		{"answer to hidden element", []*Answer{{ElementID: 1, OptionIDs: []int64{11}}, {ElementID: 2, Value: "LPC"}, {ElementID: 5, Value: "DONA"}}, []int64{2}},
+		{"answer to element hidden through another hidden element", []*Answer{{ElementID: 1, OptionIDs: []int64{11}}, {ElementID: 3, Value: "123"}, {ElementID: 5, Value: "DONA"}}, []int64{3}},
+		{"all group holds", []*Answer{{ElementID: 1, OptionIDs: []int64{10}}, {ElementID: 2, Value: "LPC"}, {ElementID: 3, Value: "123"}, {ElementID: 4, Value: "Hi"}}, []int64{5}},
+		{"all group doesn't hold", []*Answer{{ElementID: 1, OptionIDs: []int64{10}}, {ElementID: 2, Value: "LPC"}, {ElementID: 3, Value: "123"}}, nil},
+	}
+	for _, tc := range testCases {
+		invalid := validateSubmission(form, tc.answers)
+		if len(invalid) != len(tc.invalid) {
+			t.Error(tc.name+": expected invalid elements", tc.invalid, "; got", invalid)
+			continue
+		}
+		for _, elementID := range tc.invalid {
+			if _, ok := invalid[elementID]; !ok {
+				t.Error(tc.name+": expected element", elementID, "to be invalid; got", invalid)
+			}
+		}
+	}
+}
+
+func TestValidateAnswersInSection(t *testing.T) {
+	role := &forms.Element{ID: 1, OriginID: 1, Position: 0, SectionID: 1, Label: "Role", Type: forms.TypeSingleSelect, Required: true, Options: []*forms.Option{{ID: 10, OriginID: 10, Name: "Therapist"}}}
+	license := &forms.Element{ID: 2, OriginID: 2, Position: 1, SectionID: 2, Label: "License type", Type: forms.TypeShortText, Required: true, ShowIf: &forms.ConditionGroup{
+		Conditions: []*forms.Condition{{ElementID: 1, Operator: forms.OpEquals, OptionID: 10}},
+	}}
+	form := &forms.Form{ID: 1, Elements: []*forms.Element{role, license}}
+	page := []*forms.Element{license}
+
+	invalid := validateAnswers(form, page, []*Answer{}, map[int64]*forms.GivenAnswer{1: {OptionIDs: []int64{10}}})
+	if _, ok := invalid[2]; !ok {
+		t.Error("expected an earlier answer in another section to make the license type required; got", invalid)
+	}
+	invalid = validateAnswers(form, page, []*Answer{}, nil)
+	if invalid != nil {
+		t.Error("expected the license type to be hidden without an earlier answer; got", invalid)
+	}
+	invalid = validateAnswers(form, page, []*Answer{{ElementID: 1, OptionIDs: []int64{10}}, {ElementID: 2, Value: "LPC"}}, map[int64]*forms.GivenAnswer{1: {OptionIDs: []int64{10}}})
+	if _, ok := invalid[1]; !ok || len(invalid) != 1 {
+		t.Error("expected answers outside the section to be rejected; got", invalid)
+	}
+}
@@ -0,0 +1,86 @@
+package responses
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+// xlsxWriter streams a workbook with a single sheet of text cells. The fixed parts of the
+// package are written first and the sheet is written row by row after them.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+}
+
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Responses" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`},
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	x := xlsxWriter{zip: zip.NewWriter(w)}
+	for _, part := range xlsxParts {
+		f, err := x.zip.Create(part.name)
+		if err != nil {
+			return nil, errors.New("error creating workbook: " + err.Error())
+		}
+		_, err = io.WriteString(f, part.content)
+		if err != nil {
+			return nil, errors.New("error creating workbook: " + err.Error())
+		}
+	}
+	var err error
+	x.sheet, err = x.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, errors.New("error creating worksheet: " + err.Error())
+	}
+	_, err = io.WriteString(x.sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, errors.New("error creating worksheet: " + err.Error())
+	}
+	return &x, nil
+}
+
+// WriteRow writes cells as inline strings, so answers are never read as formulas
+func (x *xlsxWriter) WriteRow(cells []string) error {
+	_, err := io.WriteString(x.sheet, "<row>")
+	if err != nil {
+		return err
+	}
+	for _, cell := range cells {
+		_, err = io.WriteString(x.sheet, `<c t="inlineStr"><is><t xml:space="preserve">`)
+		if err != nil {
+			return err
+		}
+		err = xml.EscapeText(x.sheet, []byte(cell))
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(x.sheet, "</t></is></c>")
+		if err != nil {
+			return err
+		}
+	}
+	_, err = io.WriteString(x.sheet, "</row>")
+	return err
+}
+
+func (x *xlsxWriter) Close() error {
+	_, err := io.WriteString(x.sheet, "</sheetData></worksheet>")
+	if err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
@@ -0,0 +1,175 @@
+package forms
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Rules are optional constraints on an element's answers beyond its type. Each rule only
+// applies to the types it makes sense for.
+type Rules struct {
+	MinLength      *int           `json:"min_length,omitempty"` // text types
+	MaxLength      *int           `json:"max_length,omitempty"`
+	Pattern        string         `json:"pattern,omitempty"`         // must match the whole answer
+	PatternMessage string         `json:"pattern_message,omitempty"` // shown when the pattern doesn't match
+	Min            *float64       `json:"min,omitempty"`             // number
+	Max            *float64       `json:"max,omitempty"`
+	MinDate        string         `json:"min_date,omitempty"` // date, as YYYY-MM-DD
+	MaxDate        string         `json:"max_date,omitempty"`
+	MinSelections  *int           `json:"min_selections,omitempty"` // multi_select
+	MaxSelections  *int           `json:"max_selections,omitempty"`
+	pattern        *regexp.Regexp // Pattern compiled as a whole answer match, see compiledPattern
+	patternSource  string
+}
+
+var textTypes = map[string]bool{
+	TypeShortText: true,
+	TypeLongText:  true,
+	TypeEmail:     true,
+	TypePhone:     true,
+	TypeURL:       true,
+}
+
+// validate checks that the rules make sense for an element of type t
+func (r *Rules) validate(t string) error {
+	if (r.MinLength != nil || r.MaxLength != nil || r.Pattern != "") && !textTypes[t] {
+		return fmt.Errorf("length and pattern rules only apply to text elements, not %s", t)
+	}
+	if (r.Min != nil || r.Max != nil) && t != TypeNumber {
+		return fmt.Errorf("min and max only apply to number elements, not %s", t)
+	}
+	if (r.MinDate != "" || r.MaxDate != "") && t != TypeDate {
+		return fmt.Errorf("min_date and max_date only apply to date elements, not %s", t)
+	}
+	if (r.MinSelections != nil || r.MaxSelections != nil) && t != TypeMultiSelect {
+		return fmt.Errorf("min_selections and max_selections only apply to multi_select elements, not %s", t)
+	}
+	if (r.MinLength != nil && *r.MinLength < 0) || (r.MaxLength != nil && *r.MaxLength < 0) {
+		return errors.New("lengths can't be negative")
+	}
+	if r.MinLength != nil && r.MaxLength != nil && *r.MinLength > *r.MaxLength {
+		return errors.New("min_length is more than max_length")
+	}
+	if r.Pattern != "" {
+		// the pattern must be valid on its own too, or it could close the group it is wrapped in
+		_, err := regexp.Compile(r.Pattern)
+		if err == nil {
+			_, err = r.compiledPattern()
+		}
+		if err != nil {
+			return errors.New("pattern is not a valid regular expression. " + err.Error())
+		}
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return errors.New("min is more than max")
+	}
+	for _, date := range []string{r.MinDate, r.MaxDate} {
+		if date != "" {
+			if _, err := time.Parse(DateFormat, date); err != nil {
+				return fmt.Errorf("%s is not a date as YYYY-MM-DD", date)
+			}
+		}
+	}
+	if r.MinDate != "" && r.MaxDate != "" && r.MinDate > r.MaxDate {
+		return errors.New("min_date is after max_date")
+	}
+	if (r.MinSelections != nil && *r.MinSelections < 0) || (r.MaxSelections != nil && *r.MaxSelections < 0) {
+		return errors.New("selections can't be negative")
+	}
+	if r.MinSelections != nil && r.MaxSelections != nil && *r.MinSelections > *r.MaxSelections {
+		return errors.New("min_selections is more than max_selections")
+	}
+	return nil
+}
+
+// compiledPattern compiles the expression answers must match, which is Pattern anchored to the
+// whole answer. It is compiled once for each pattern the rules hold.
+func (r *Rules) compiledPattern() (*regexp.Regexp, error) {
+	if r.pattern == nil || r.patternSource != r.Pattern {
+		pattern, err := regexp.Compile("^(?:" + r.Pattern + ")$")
+		if err != nil {
+			return nil, err
+		}
+		r.pattern = pattern
+		r.patternSource = r.Pattern
+	}
+	return r.pattern, nil
+}
+
+// check returns a message for the first rule a non-empty, normalized answer breaks. The error
+// is for rules that can't be checked, like a pattern saved before patterns were validated.
+func (r *Rules) check(value string, optionIDs []int64) (string, error) {
+	if r.MinLength != nil && value != "" && utf8.RuneCountInString(value) < *r.MinLength {
+		return fmt.Sprintf("enter at least %d characters", *r.MinLength), nil
+	}
+	if r.MaxLength != nil && utf8.RuneCountInString(value) > *r.MaxLength {
+		return fmt.Sprintf("enter at most %d characters", *r.MaxLength), nil
+	}
+	if r.Pattern != "" && value != "" {
+		pattern, err := r.compiledPattern()
+		if err != nil {
+			return "", errors.New("pattern is not a valid regular expression. " + err.Error())
+		}
+		if !pattern.MatchString(value) {
+			if r.PatternMessage != "" {
+				return r.PatternMessage, nil
+			}
+			return "this answer is not in the expected format", nil
+		}
+	}
+	if (r.Min != nil || r.Max != nil) && value != "" {
+		n, _ := strconv.ParseFloat(value, 64)
+		if r.Min != nil && n < *r.Min {
+			return "enter a number no less than " + strconv.FormatFloat(*r.Min, 'f', -1, 64), nil
+		}
+		if r.Max != nil && n > *r.Max {
+			return "enter a number no more than " + strconv.FormatFloat(*r.Max, 'f', -1, 64), nil
+		}
+	}
+	// normalized dates compare correctly as strings
+	if r.MinDate != "" && value != "" && value < r.MinDate {
+		return "enter a date on or after " + r.MinDate, nil
+	}
+	if r.MaxDate != "" && value != "" && value > r.MaxDate {
+		return "enter a date on or before " + r.MaxDate, nil
+	}
+	if len(optionIDs) > 0 {
+		if r.MinSelections != nil && len(optionIDs) < *r.MinSelections {
+			return fmt.Sprintf("choose at least %d options", *r.MinSelections), nil
+		}
+		if r.MaxSelections != nil && len(optionIDs) > *r.MaxSelections {
+			return fmt.Sprintf("choose at most %d options", *r.MaxSelections), nil
+		}
+	}
+	return "", nil
+}
+
+// rulesValue stores rules as JSON, or null when there are none
+func rulesValue(rules *Rules) (interface{}, error) {
+	if rules == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return nil, errors.New("failed to encode rules: " + err.Error())
+	}
+	return string(data), nil
+}
+
+func parseRules(data sql.NullString) (*Rules, error) {
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+	var rules Rules
+	err := json.Unmarshal([]byte(data.String), &rules)
+	if err != nil {
+		return nil, errors.New("failed to decode rules: " + err.Error())
+	}
+	return &rules, nil
+}
@@ -0,0 +1,151 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Sections split a form into pages. Like elements they belong to a single version of the
+// form and keep an origin across versions. Forms without sections are shown as one
+// implicit section holding every element.
+
+// ErrSectionNotFound is returned for sections that aren't part of a form's current version
+var ErrSectionNotFound = errors.New("section not found")
+
+type Section struct {
+	ID          int64      `json:"id"` // 0 for the implicit section
+	FormID      int64      `json:"form_id"`
+	VersionID   int64      `json:"-"`
+	OriginID    int64      `json:"origin_id"` // the same section in every version of the form
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Position    int        `json:"position"`           // index
+	Implicit    bool       `json:"implicit,omitempty"` // holds the elements that aren't in a section
+	Elements    []*Element `json:"elements"`
+}
+
+const sectionColumns = "id, formID, versionID, originID, title, description, position"
+
+// getVersionSections returns the sections of a form version in position order, without elements
+func getVersionSections(versionID int64, db Querier) ([]*Section, error) {
+	rows, err := db.Query("SELECT "+sectionColumns+" FROM sections WHERE versionID = ? ORDER BY position, id", versionID)
+	if err != nil {
+		return nil, errors.New("failed to get sections: " + err.Error())
+	}
+	defer rows.Close()
+	sections := []*Section{}
+	for rows.Next() {
+		var section Section
+		err := rows.Scan(&section.ID, &section.FormID, &section.VersionID, &section.OriginID, &section.Title, &section.Description, &section.Position)
+		if err != nil {
+			return nil, errors.New("failed to scan section: " + err.Error())
+		}
+		sections = append(sections, &section)
+	}
+	return sections, nil
+}
+
+// nestElements fills in the elements of each of the form's sections from form.Elements. Elements
+// that aren't in a section go in an implicit section, which is the only section of a form
+// that doesn't have any.
+func nestElements(form *Form) {
+	var sections []*Section
+	byID := map[int64]*Section{}
+	for _, section := range form.Sections {
+		if section.Implicit {
+			continue
+		}
+		section.Elements = []*Element{}
+		sections = append(sections, section)
+		byID[section.ID] = section
+	}
+	implicit := &Section{FormID: form.ID, Title: form.Name, Position: len(sections), Implicit: true, Elements: []*Element{}}
+	for _, element := range form.Elements {
+		section, ok := byID[element.SectionID]
+		if !ok {
+			section = implicit
+		}
+		section.Elements = append(section.Elements, element)
+	}
+	if len(implicit.Elements) > 0 || len(sections) == 0 {
+		sections = append(sections, implicit)
+	}
+	form.Sections = sections
+}
+
+// flattenSections takes a form's elements from its sections when it has any besides the implicit
+// one, in section order, and drops the implicit section. The elements are numbered by their place
+// in the whole form. Forms read back with only the implicit section are flat, so their top level
+// elements are kept.
+func flattenSections(form *Form) error {
+	sectioned := false
+	for _, section := range form.Sections {
+		sectioned = sectioned || !section.Implicit
+	}
+	if !sectioned {
+		form.Sections = []*Section{}
+		for _, element := range form.Elements {
+			element.section = nil
+		}
+		return nil
+	}
+	sections := append([]*Section{}, form.Sections...)
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Position < sections[j].Position
+	})
+	form.Sections = []*Section{}
+	form.Elements = []*Element{}
+	for _, section := range sections {
+		if !section.Implicit {
+			if section.Title == "" {
+				return fmt.Errorf("%w: sections must have a title", ErrInvalidForm)
+			}
+			section.Position = len(form.Sections)
+			form.Sections = append(form.Sections, section)
+		}
+		elements := append([]*Element{}, section.Elements...)
+		sort.SliceStable(elements, func(i, j int) bool {
+			return elements[i].Position < elements[j].Position
+		})
+		for _, element := range elements {
+			element.section = nil
+			if !section.Implicit {
+				element.section = section
+			}
+			element.Position = len(form.Elements)
+			form.Elements = append(form.Elements, element)
+		}
+		section.Elements = nil
+	}
+	return nil
+}
+
+// NewSection inserts a section into section.VersionID. New sections are their own origin.
+func NewSection(section *Section, db Querier) (*Section, error) {
+	resp, err := db.Exec("INSERT INTO sections (formID, versionID, title, description, position) VALUES (?, ?, ?, ?, ?)", section.FormID, section.VersionID, section.Title, section.Description, section.Position)
+	if err != nil {
+		return nil, errors.New("failed to insert section: " + err.Error())
+	}
+	id, err := resp.LastInsertId()
+	if err != nil {
+		return nil, errors.New("failed to get inserted section id: " + err.Error())
+	}
+	section.ID = id
+	if section.OriginID == 0 {
+		section.OriginID = id
+	}
+	_, err = db.Exec("UPDATE sections SET originID = ? WHERE id = ?", section.OriginID, id)
+	if err != nil {
+		return nil, errors.New("failed to set section origin: " + err.Error())
+	}
+	return section, nil
+}
+
+func UpdateSection(section *Section, db Querier) error {
+	_, err := db.Exec("UPDATE sections SET title = ?, description = ?, position = ? WHERE id = ?", section.Title, section.Description, section.Position, section.ID)
+	if err != nil {
+		return errors.New("failed to update section: " + err.Error())
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package tally
 
 import (
+	"api/encryption"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -37,7 +38,30 @@ func (q *QnA) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
-func GetPrettyResponse(id int64, db *sql.DB) (*PrettyResponse, error) {
+// sealFields seals the JSON fields of a response, storing them as a JSON string
+func sealFields(fields []byte, keys *encryption.Keyring) ([]byte, error) {
+	sealed, err := keys.Seal(string(fields))
+	if err != nil {
+		return nil, errors.New("error sealing fields. " + err.Error())
+	}
+	return json.Marshal(sealed)
+}
+
+// openFields returns the JSON fields of a stored response. Responses saved before fields
+// were sealed hold the fields themselves.
+func openFields(stored []byte, keys *encryption.Keyring) ([]byte, error) {
+	var sealed string
+	if json.Unmarshal(stored, &sealed) != nil {
+		return stored, nil
+	}
+	fields, err := keys.Open(sealed)
+	if err != nil {
+		return nil, errors.New("error opening fields. " + err.Error())
+	}
+	return []byte(fields), nil
+}
+
+func GetPrettyResponse(id int64, keys *encryption.Keyring, db *sql.DB) (*PrettyResponse, error) {
 	query := "select r.id, f.name, r.created_at, u.firstName, u.lastName, u.email, r.fields from tally_responses r, users u, tally_forms f where r.user_id = u.id and r.form_id = f.id and r.id = ?"
 	row := db.QueryRow(query, id)
 	var response PrettyResponse
@@ -50,9 +74,65 @@ func GetPrettyResponse(id int64, db *sql.DB) (*PrettyResponse, error) {
 	}
 	response.UserFirstName = firstName.String
 	response.UserLastName = lastName.String
+	fields, err = openFields(fields, keys)
+	if err != nil {
+		return nil, err
+	}
 	err = json.Unmarshal(fields, &response.Questions)
 	if err != nil {
 		return nil, errors.New("error unmarshalling fields: " + err.Error())
 	}
 	return &response, nil
 }
+
+// Reencrypt seals the fields of every stored response with the current key, including
+// responses saved before fields were sealed. It returns how many responses changed.
+func Reencrypt(keys *encryption.Keyring, db *sql.DB) (int, error) {
+	changed := 0
+	var lastID int64
+	for {
+		rows, err := db.Query("select id, fields from tally_responses where id > ? order by id limit 500", lastID)
+		if err != nil {
+			return changed, errors.New("error selecting responses to reencrypt: " + err.Error())
+		}
+		type storedFields struct {
+			id     int64
+			fields []byte
+		}
+		var batch []storedFields
+		for rows.Next() {
+			var stored storedFields
+			err := rows.Scan(&stored.id, &stored.fields)
+			if err != nil {
+				rows.Close()
+				return changed, errors.New("error scanning response to reencrypt: " + err.Error())
+			}
+			batch = append(batch, stored)
+		}
+		rows.Close()
+		if len(batch) == 0 {
+			return changed, nil
+		}
+		for _, stored := range batch {
+			lastID = stored.id
+			var sealed string
+			if json.Unmarshal(stored.fields, &sealed) == nil && keys.Current(sealed) {
+				continue
+			}
+			fields, err := openFields(stored.fields, keys)
+			if err != nil {
+				return changed, err
+			}
+			resealed, err := sealFields(fields, keys)
+			if err != nil {
+				return changed, err
+			}
+			// tally responses are never edited, so there is no need to check for changes
+			_, err = db.Exec("update tally_responses set fields = ? where id = ?", resealed, stored.id)
+			if err != nil {
+				return changed, errors.New("error updating reencrypted response: " + err.Error())
+			}
+			changed++
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package tally
 
 import (
+	"api/encryption"
 	"database/sql"
 	"encoding/json"
 	"errors"
@@ -15,7 +16,7 @@ type Event struct {
 	Data      EventData `json:"data"`
 }
 
-func (e *Event) SaveResponse(db *sql.DB) (*Response, error) {
+func (e *Event) SaveResponse(keys *encryption.Keyring, db *sql.DB) (*Response, error) {
 	fields := e.Data.Fields
 	if len(fields) == 0 {
 		return nil, errors.New("no fields in event data")
@@ -54,7 +55,7 @@ func (e *Event) SaveResponse(db *sql.DB) (*Response, error) {
 		UserID:    userID,
 		Fields:    fields,
 	}
-	err = response.Save(db)
+	err = response.Save(keys, db)
 	if err != nil {
 		return nil, errors.New("error saving response. " + err.Error())
 	}
@@ -70,7 +71,9 @@ type Response struct {
 	Fields    []Field   `json:"fields"`
 }
 
-func (r *Response) Save(db *sql.DB) error {
+// Save stores the response with its fields sealed, since Tally forms can't mark which
+// questions are sensitive
+func (r *Response) Save(keys *encryption.Keyring, db *sql.DB) error {
 	if r.ID != 0 {
 		return errors.New("response already saved")
 	}
@@ -79,6 +82,10 @@ func (r *Response) Save(db *sql.DB) error {
 	if err != nil {
 		return errors.New("error marshalling fields. " + err.Error())
 	}
+	fields, err = sealFields(fields, keys)
+	if err != nil {
+		return err
+	}
 	createdAt := r.CreatedAt.Format("2006-01-02 15:04:05")
 	result, err := db.Exec(query, r.EventID, r.FormID, createdAt, r.UserID, fields)
 	if err != nil {
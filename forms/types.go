@@ -0,0 +1,264 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// element types
+const (
+	TypeShortText    = "short_text"
+	TypeLongText     = "long_text"
+	TypeEmail        = "email"
+	TypePhone        = "phone"
+	TypeURL          = "url"
+	TypeNumber       = "number"
+	TypeDate         = "date"
+	TypeBoolean      = "boolean"
+	TypeSingleSelect = "single_select"
+	TypeMultiSelect  = "multi_select"
+)
+
+// DateFormat is the layout date answers are stored in
+const DateFormat = "2006-01-02"
+
+// ErrInvalidAnswer matches every AnswerError with errors.Is
+var ErrInvalidAnswer = errors.New("invalid answer")
+
+// AnswerError explains why an answer doesn't fit its element, in words that can be shown
+// next to the question
+type AnswerError struct {
+	Message string
+}
+
+func (e *AnswerError) Error() string {
+	return e.Message
+}
+
+func (e *AnswerError) Is(target error) bool {
+	return target == ErrInvalidAnswer
+}
+
+func answerError(format string, args ...interface{}) error {
+	return &AnswerError{Message: fmt.Sprintf(format, args...)}
+}
+
+// ErrInvalidForm is wrapped by errors for forms that can't be saved as given
+var ErrInvalidForm = errors.New("invalid form")
+
+// elementType describes how answers to an element are given. Select types are answered
+// with option IDs; every other type is answered with a value that normalize checks and
+// cleans up before it is stored.
+type elementType struct {
+	selectOptions bool
+	multiple      bool
+	normalize     func(value string) (string, error)
+}
+
+var elementTypes = map[string]elementType{
+	TypeShortText:    {normalize: normalizeShortText},
+	TypeLongText:     {normalize: normalizeLongText},
+	TypeEmail:        {normalize: normalizeEmail},
+	TypePhone:        {normalize: normalizePhone},
+	TypeURL:          {normalize: normalizeURL},
+	TypeNumber:       {normalize: normalizeNumber},
+	TypeDate:         {normalize: normalizeDate},
+	TypeBoolean:      {normalize: normalizeBoolean},
+	TypeSingleSelect: {selectOptions: true},
+	TypeMultiSelect:  {selectOptions: true, multiple: true},
+}
+
+// ValidType reports whether t is a known element type
+func ValidType(t string) bool {
+	_, ok := elementTypes[t]
+	return ok
+}
+
+// IsSelect reports whether the element is answered with options rather than a value
+func (e *Element) IsSelect() bool {
+	return elementTypes[e.Type].selectOptions
+}
+
+// ValidateAnswer checks an answer against the element's type and returns the value to store.
+// An empty answer is valid here; whether the element must be answered is checked separately.
+func (e *Element) ValidateAnswer(value string, optionIDs []int64) (string, error) {
+	t, ok := elementTypes[e.Type]
+	if !ok {
+		return "", answerError("element %v has unknown type %s", e.ID, e.Type)
+	}
+	if e.Archived {
+		return "", answerError("this question has been removed from the form")
+	}
+	if !t.selectOptions {
+		if len(optionIDs) > 0 {
+			return "", answerError("this question doesn't have options to choose from")
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			return "", nil
+		}
+		normalized, err := t.normalize(value)
+		if err != nil {
+			return "", &AnswerError{Message: err.Error()}
+		}
+		if e.Rules != nil {
+			msg, err := e.Rules.check(normalized, nil)
+			if err != nil {
+				return "", err
+			}
+			if msg != "" {
+				return "", &AnswerError{Message: msg}
+			}
+		}
+		return normalized, nil
+	}
+
+	if value != "" {
+		return "", answerError("choose from the options instead of entering a value")
+	}
+	if !t.multiple && len(optionIDs) > 1 {
+		return "", answerError("choose only one option")
+	}
+	options := map[int64]bool{}
+	for _, option := range e.Options {
+		options[option.ID] = !option.Archived
+	}
+	chosen := map[int64]bool{}
+	for _, optionID := range optionIDs {
+		if !options[optionID] {
+			return "", answerError("option %v is not an option for this question", optionID)
+		}
+		if chosen[optionID] {
+			return "", answerError("option %v is chosen more than once", optionID)
+		}
+		chosen[optionID] = true
+	}
+	if e.Rules != nil {
+		msg, err := e.Rules.check("", optionIDs)
+		if err != nil {
+			return "", err
+		}
+		if msg != "" {
+			return "", &AnswerError{Message: msg}
+		}
+	}
+	return "", nil
+}
+
+// validateElements checks that every element in a form has a known type, sensible rules, a
+// known visibility and can be sensitive if it is
+func validateElements(elements []*Element) error {
+	for _, element := range elements {
+		if !ValidType(element.Type) {
+			return fmt.Errorf("%w: element %q has unknown type %q", ErrInvalidForm, element.Label, element.Type)
+		}
+		if !element.IsSelect() && len(element.Options) > 0 {
+			return fmt.Errorf("%w: element %q has options but its type %s does not use them", ErrInvalidForm, element.Label, element.Type)
+		}
+		if element.Rules != nil {
+			err := element.Rules.validate(element.Type)
+			if err != nil {
+				return fmt.Errorf("%w: element %q has invalid rules. %s", ErrInvalidForm, element.Label, err.Error())
+			}
+		}
+		err := validateVisibility(element)
+		if err != nil {
+			return fmt.Errorf("%w: element %q has invalid visibility. %s", ErrInvalidForm, element.Label, err.Error())
+		}
+		// only values are encrypted, and the provider directory indexes searchable answers in plaintext
+		if element.Sensitive && (element.IsSelect() || element.Search) {
+			return fmt.Errorf("%w: element %q can't be sensitive. Sensitive elements must be answered with a value and can't be searchable", ErrInvalidForm, element.Label)
+		}
+	}
+	return nil
+}
+
+func normalizeShortText(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", errors.New("enter a single line of text")
+	}
+	return value, nil
+}
+
+func normalizeLongText(value string) (string, error) {
+	return strings.ReplaceAll(value, "\r\n", "\n"), nil
+}
+
+func normalizeEmail(value string) (string, error) {
+	address, err := mail.ParseAddress(value)
+	if err != nil || address.Address != value {
+		return "", errors.New("enter a valid email address")
+	}
+	at := strings.LastIndex(value, "@")
+	return value[:at] + strings.ToLower(value[at:]), nil
+}
+
+// normalizePhone strips formatting, keeping a leading + for international numbers
+func normalizePhone(value string) (string, error) {
+	var digits strings.Builder
+	for i, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '+' && i == 0:
+			digits.WriteRune(r)
+		case strings.ContainsRune(" -().", r):
+		default:
+			return "", errors.New("enter a valid phone number")
+		}
+	}
+	phone := digits.String()
+	count := len(strings.TrimPrefix(phone, "+"))
+	if count < 7 || count > 15 {
+		return "", errors.New("enter a valid phone number")
+	}
+	return phone, nil
+}
+
+// normalizeURL accepts web addresses with or without a scheme
+func normalizeURL(value string) (string, error) {
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(u.Host, " ") {
+		return "", errors.New("enter a valid web address")
+	}
+	return u.String(), nil
+}
+
+func normalizeNumber(value string) (string, error) {
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) {
+		return "", errors.New("enter a number")
+	}
+	return strconv.FormatFloat(n, 'f', -1, 64), nil
+}
+
+func normalizeDate(value string) (string, error) {
+	date, err := time.Parse(DateFormat, value)
+	if err != nil {
+		return "", errors.New("enter a date as YYYY-MM-DD")
+	}
+	return date.Format(DateFormat), nil
+}
+
+func normalizeBoolean(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "yes", "y":
+		return "true", nil
+	case "no", "n":
+		return "false", nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", errors.New("answer yes or no")
+	}
+	return strconv.FormatBool(b), nil
+}
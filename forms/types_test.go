@@ -0,0 +1,107 @@
+package forms_test
+
+import (
+	"api/forms"
+	"errors"
+	"testing"
+)
+
+func TestValidateAnswer(t *testing.T) {
+	options := []*forms.Option{{ID: 1, Name: "English"}, {ID: 2, Name: "Spanish"}}
+	testCases := []struct {
+		elementType string
+		value       string
+		optionIDs   []int64
+		expected    string
+		valid       bool
+	}{
+		{forms.TypeShortText, "  Sam  ", nil, "Sam", true},
+		{forms.TypeShortText, "two\nlines", nil, "", false},
+		{forms.TypeLongText, "two\r\nlines", nil, "two\nlines", true},
+		{forms.TypeEmail, "sam@Example.COM", nil, "sam@example.com", true},
+		{forms.TypeEmail, "Sam <sam@example.com>", nil, "", false},
+		{forms.TypePhone, "(303) 555-0100", nil, "3035550100", true},
+		{forms.TypePhone, "+44 20 7946 0958", nil, "+442079460958", true},
+		{forms.TypePhone, "call me", nil, "", false},
+		{forms.TypeURL, "example.com/about", nil, "https://example.com/about", true},
+		{forms.TypeURL, "ftp://example.com", nil, "", false},
+		{forms.TypeNumber, "1.50", nil, "1.5", true},
+		{forms.TypeNumber, "ten", nil, "", false},
+		{forms.TypeDate, "2022-02-28", nil, "2022-02-28", true},
+		{forms.TypeDate, "2022-02-30", nil, "", false},
+		{forms.TypeBoolean, "Yes", nil, "true", true},
+		{forms.TypeBoolean, "maybe", nil, "", false},
+		{forms.TypeShortText, "", nil, "", true},
+		{forms.TypeShortText, "", []int64{1}, "", false},
+		{forms.TypeSingleSelect, "", []int64{2}, "", true},
+		{forms.TypeSingleSelect, "", []int64{1, 2}, "", false},
+		{forms.TypeSingleSelect, "Spanish", nil, "", false},
+		{forms.TypeMultiSelect, "", []int64{1, 2}, "", true},
+		{forms.TypeMultiSelect, "", []int64{1, 1}, "", false},
+		{forms.TypeMultiSelect, "", []int64{3}, "", false},
+		{"text", "Sam", nil, "", false},
+	}
+	for _, tc := range testCases {
+		element := forms.Element{ID: 1, Type: tc.elementType, Options: options}
+		value, err := element.ValidateAnswer(tc.value, tc.optionIDs)
+		if tc.valid && err != nil {
+			t.Error("expected", tc.elementType, "answer", tc.value, tc.optionIDs, "to be valid; got", err)
+			continue
+		}
+		if !tc.valid {
+			if !errors.Is(err, forms.ErrInvalidAnswer) {
+				t.Error("expected", tc.elementType, "answer", tc.value, tc.optionIDs, "to be invalid; got", err)
+			}
+			continue
+		}
+		if value != tc.expected {
+			t.Error("expected", tc.elementType, "answer", tc.value, "to be normalized to", tc.expected, "; got", value)
+		}
+	}
+}
+
+func TestValidateAnswerRules(t *testing.T) {
+	three := 3
+	ten := 10
+	one := 1.0
+	five := 5.0
+	options := []*forms.Option{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}}
+	testCases := []struct {
+		name      string
+		element   forms.Element
+		value     string
+		optionIDs []int64
+		message   string
+	}{
+		{"max length", forms.Element{Type: forms.TypeLongText, Rules: &forms.Rules{MaxLength: &three}}, "abcd", nil, "enter at most 3 characters"},
+		{"min length", forms.Element{Type: forms.TypeShortText, Rules: &forms.Rules{MinLength: &three}}, "ab", nil, "enter at least 3 characters"},
+		{"pattern", forms.Element{Type: forms.TypePhone, Rules: &forms.Rules{Pattern: `\d{10}`, PatternMessage: "enter a 10 digit phone number"}}, "+1 303 555 0100", nil, "enter a 10 digit phone number"},
+		{"pattern matches", forms.Element{Type: forms.TypePhone, Rules: &forms.Rules{Pattern: `\d{10}`}}, "303-555-0100", nil, ""},
+		{"below min", forms.Element{Type: forms.TypeNumber, Rules: &forms.Rules{Min: &one, Max: &five}}, "0.5", nil, "enter a number no less than 1"},
+		{"above max", forms.Element{Type: forms.TypeNumber, Rules: &forms.Rules{Min: &one, Max: &five}}, "6", nil, "enter a number no more than 5"},
+		{"before min date", forms.Element{Type: forms.TypeDate, Rules: &forms.Rules{MinDate: "2020-01-01"}}, "2019-12-31", nil, "enter a date on or after 2020-01-01"},
+		{"too many options", forms.Element{Type: forms.TypeMultiSelect, Options: options, Rules: &forms.Rules{MaxSelections: &three}}, "", []int64{1, 2, 3, 4}, "choose at most 3 options"},
+		{"enough options", forms.Element{Type: forms.TypeMultiSelect, Options: options, Rules: &forms.Rules{MaxSelections: &ten}}, "", []int64{1, 2, 3, 4}, ""},
+	}
+	for _, tc := range testCases {
+		_, err := tc.element.ValidateAnswer(tc.value, tc.optionIDs)
+		if tc.message == "" {
+			if err != nil {
+				t.Error(tc.name+": expected answer to be valid; got", err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tc.message {
+			t.Error(tc.name+": expected", tc.message, "; got", err)
+		}
+	}
+}
+
+func TestValidateAnswerBrokenPattern(t *testing.T) {
+	// patterns saved before they were validated can't be compiled
+	element := forms.Element{Type: forms.TypeShortText, Rules: &forms.Rules{Pattern: "("}}
+	_, err := element.ValidateAnswer("abc", nil)
+	if err == nil || errors.Is(err, forms.ErrInvalidAnswer) {
+		t.Error("expected an error that isn't the answer's fault; got", err)
+	}
+}
@@ -0,0 +1,347 @@
+package forms
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// FormDiff lists the element IDs changed by UpdateForm
+type FormDiff struct {
+	Added    []int64 `json:"added"`
+	Updated  []int64 `json:"updated"`
+	Moved    []int64 `json:"moved"`
+	Deleted  []int64 `json:"deleted"`
+	Archived []int64 `json:"archived"`
+}
+
+// UpdateForm makes the form's draft version match the submitted form, creating the draft from
+// the current version first if there isn't one. Published versions are never changed. Element
+// and option IDs from the version the draft was copied from are mapped to their copies.
+//
+// If the form has sections other than the implicit one they are synced the same way and
+// elements are taken from them; otherwise the draft's sections are removed.
+//
+// Elements and options in the draft but not in the submitted form are deleted. If they were
+// answered in any version they are archived instead, unless form.DeleteAnswered is set.
+// Positions are renumbered from 0 in the submitted order, with archived items last. Everything
+// happens in one transaction.
+func UpdateForm(form *Form, db *sql.DB) (*FormDiff, error) {
+	err := flattenSections(form)
+	if err != nil {
+		return nil, err
+	}
+	err = validateElements(form.Elements)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("failed to start transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	err = lockForm(form.ID, tx)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec("UPDATE forms SET name = ?, required = ?, live = ? WHERE id = ?", form.Name, form.Required, form.Live, form.ID)
+	if err != nil {
+		return nil, errors.New("failed to update form: " + err.Error())
+	}
+	draft, err := getDraft(form.ID, tx)
+	if err != nil {
+		return nil, err
+	}
+	keptSections, err := syncSections(form, draft, tx)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := getVersionElements(draft.versionID, tx)
+	if err != nil {
+		return nil, err
+	}
+	existingByID := map[int64]*Element{}
+	for _, element := range existing {
+		existingByID[element.ID] = element
+	}
+
+	// map IDs onto the draft and make sure they belong to it
+	kept := map[int64]bool{}
+	for _, element := range form.Elements {
+		element.FormID = form.ID
+		element.VersionID = draft.versionID
+		element.SectionID = 0
+		if element.section != nil {
+			element.SectionID = element.section.ID
+		}
+		if element.ID <= 0 {
+			element.ID = 0
+			element.OriginID = 0
+			for _, option := range element.Options {
+				option.ID = 0
+				option.OriginID = 0
+			}
+			continue
+		}
+		if copied, ok := draft.elements[element.ID]; ok {
+			element.ID = copied
+		}
+		current, ok := existingByID[element.ID]
+		if !ok || kept[element.ID] {
+			return nil, fmt.Errorf("%w: element %v is not part of this form's draft or is listed twice", ErrInvalidForm, element.ID)
+		}
+		kept[element.ID] = true
+		element.OriginID = current.OriginID
+		currentOptions := map[int64]*Option{}
+		for _, option := range current.Options {
+			currentOptions[option.ID] = option
+		}
+		for _, option := range element.Options {
+			if copied, ok := draft.options[option.ID]; ok {
+				option.ID = copied
+			}
+			if option.ID <= 0 {
+				option.ID = 0
+				option.OriginID = 0
+				continue
+			}
+			currentOption, ok := currentOptions[option.ID]
+			if !ok {
+				return nil, fmt.Errorf("%w: option %v is not part of element %v", ErrInvalidForm, option.ID, element.ID)
+			}
+			option.OriginID = currentOption.OriginID
+		}
+	}
+
+	diff := FormDiff{Added: []int64{}, Updated: []int64{}, Moved: []int64{}, Deleted: []int64{}, Archived: []int64{}}
+	elements := append([]*Element{}, form.Elements...)
+	for _, element := range existing {
+		if kept[element.ID] {
+			continue
+		}
+		answered, err := elementAnswered(element.OriginID, tx)
+		if err != nil {
+			return nil, err
+		}
+		if answered && !form.DeleteAnswered {
+			if !element.Archived {
+				diff.Archived = append(diff.Archived, element.ID)
+			}
+			element.Archived = true
+			if !keptSections[element.SectionID] {
+				element.SectionID = 0
+			}
+			elements = append(elements, element)
+			continue
+		}
+		err = deleteElement(element.ID, tx)
+		if err != nil {
+			return nil, err
+		}
+		diff.Deleted = append(diff.Deleted, element.ID)
+	}
+	sortByPosition(elements)
+	err = validateConditions(elements)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, element := range elements {
+		if element.ID == 0 {
+			_, err := NewElement(element, tx)
+			if err != nil {
+				return nil, errors.New("failed to create element: " + err.Error())
+			}
+			diff.Added = append(diff.Added, element.ID)
+			continue
+		}
+		current := existingByID[element.ID]
+		if kept[element.ID] {
+			if elementChanged(current, element) {
+				diff.Updated = append(diff.Updated, element.ID)
+			}
+			if current.Position != element.Position || current.SectionID != element.SectionID {
+				diff.Moved = append(diff.Moved, element.ID)
+			}
+		}
+		err := syncOptions(element, current, form.DeleteAnswered, tx)
+		if err != nil {
+			return nil, err
+		}
+		err = UpdateElement(element, tx)
+		if err != nil {
+			return nil, errors.New("failed to update element: " + err.Error())
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("failed to commit form: " + err.Error())
+	}
+	return &diff, nil
+}
+
+// sortByPosition orders elements by their submitted position, keeping archived elements
+// last, and renumbers them from 0. Options are renumbered the same way.
+func sortByPosition(elements []*Element) {
+	sort.SliceStable(elements, func(i, j int) bool {
+		if elements[i].Archived != elements[j].Archived {
+			return !elements[i].Archived
+		}
+		return elements[i].Position < elements[j].Position
+	})
+	for i, element := range elements {
+		element.Position = i
+		sort.SliceStable(element.Options, func(i, j int) bool {
+			a, b := element.Options[i], element.Options[j]
+			if a.Archived != b.Archived {
+				return !a.Archived
+			}
+			return a.Position < b.Position
+		})
+		for j, option := range element.Options {
+			option.Position = j
+		}
+	}
+}
+
+// syncOptions deletes or archives the options of current that are missing from element, so
+// UpdateElement only has to insert and update
+func syncOptions(element *Element, current *Element, deleteAnswered bool, db Querier) error {
+	kept := map[int64]bool{}
+	for _, option := range element.Options {
+		kept[option.ID] = true
+	}
+	var archived []*Option
+	for _, option := range current.Options {
+		if kept[option.ID] {
+			continue
+		}
+		answered, err := optionAnswered(option.OriginID, db)
+		if err != nil {
+			return err
+		}
+		if answered && !deleteAnswered {
+			option.Archived = true
+			archived = append(archived, option)
+			continue
+		}
+		_, err = db.Exec("DELETE FROM options WHERE id = ?", option.ID)
+		if err != nil {
+			return errors.New("failed to delete option: " + err.Error())
+		}
+	}
+	// options are already in order, so newly archived ones go last
+	element.Options = append(element.Options, archived...)
+	for i, option := range element.Options {
+		option.Position = i
+	}
+	return nil
+}
+
+// syncSections makes the draft's sections match form.Sections, mapping IDs from the version the
+// draft was copied from, and returns the IDs of the sections it kept. Sections aren't answered,
+// so the ones left out are deleted.
+func syncSections(form *Form, d *draft, db Querier) (map[int64]bool, error) {
+	existing, err := getVersionSections(d.versionID, db)
+	if err != nil {
+		return nil, err
+	}
+	existingByID := map[int64]*Section{}
+	for _, section := range existing {
+		existingByID[section.ID] = section
+	}
+	kept := map[int64]bool{}
+	for _, section := range form.Sections {
+		section.FormID = form.ID
+		section.VersionID = d.versionID
+		if copied, ok := d.sections[section.ID]; ok {
+			section.ID = copied
+		}
+		if section.ID <= 0 {
+			section.ID = 0
+			section.OriginID = 0
+			_, err := NewSection(section, db)
+			if err != nil {
+				return nil, err
+			}
+			kept[section.ID] = true
+			continue
+		}
+		current, ok := existingByID[section.ID]
+		if !ok || kept[section.ID] {
+			return nil, fmt.Errorf("%w: section %v is not part of this form's draft or is listed twice", ErrInvalidForm, section.ID)
+		}
+		kept[section.ID] = true
+		section.OriginID = current.OriginID
+		err := UpdateSection(section, db)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, section := range existing {
+		if kept[section.ID] {
+			continue
+		}
+		_, err := db.Exec("DELETE FROM sections WHERE id = ?", section.ID)
+		if err != nil {
+			return nil, errors.New("failed to delete section: " + err.Error())
+		}
+	}
+	return kept, nil
+}
+
+// elementChanged reports whether anything other than an element's position changed
+func elementChanged(current *Element, updated *Element) bool {
+	if current.Label != updated.Label || current.Type != updated.Type || current.Required != updated.Required ||
+		current.Priority != updated.Priority || current.Search != updated.Search || current.Visibility != updated.Visibility || current.Sensitive != updated.Sensitive || current.Archived != updated.Archived {
+		return true
+	}
+	currentRules, _ := json.Marshal(current.Rules)
+	updatedRules, _ := json.Marshal(updated.Rules)
+	currentShowIf, _ := json.Marshal(current.ShowIf)
+	updatedShowIf, _ := json.Marshal(updated.ShowIf)
+	if string(currentRules) != string(updatedRules) || string(currentShowIf) != string(updatedShowIf) || len(current.Options) != len(updated.Options) {
+		return true
+	}
+	for i, option := range updated.Options {
+		previous := current.Options[i]
+		if option.ID != previous.ID || option.Name != previous.Name || option.Archived != previous.Archived {
+			return true
+		}
+	}
+	return false
+}
+
+// elementAnswered reports whether the element has been answered in any version of the form
+func elementAnswered(originID int64, db Querier) (bool, error) {
+	var answered bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM responses r, elements e WHERE r.elementID = e.id AND e.originID = ?)", originID).Scan(&answered)
+	if err != nil {
+		return false, errors.New("failed to check element responses: " + err.Error())
+	}
+	return answered, nil
+}
+
+// optionAnswered reports whether the option has been chosen in any version of the form
+func optionAnswered(originID int64, db Querier) (bool, error) {
+	var answered bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM response_options ro, options o WHERE ro.optionID = o.id AND o.originID = ?)", originID).Scan(&answered)
+	if err != nil {
+		return false, errors.New("failed to check option responses: " + err.Error())
+	}
+	return answered, nil
+}
+
+func deleteElement(id int64, db Querier) error {
+	_, err := db.Exec("DELETE FROM options WHERE elementID = ?", id)
+	if err != nil {
+		return errors.New("failed to delete options: " + err.Error())
+	}
+	_, err = db.Exec("DELETE FROM elements WHERE id = ?", id)
+	if err != nil {
+		return errors.New("failed to delete element: " + err.Error())
+	}
+	return nil
+}
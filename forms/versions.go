@@ -0,0 +1,194 @@
+package forms
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Every change to a form's questions is made in a draft version. Publishing a draft freezes
+// it and makes it the version that is shown and answered, so responses to earlier versions
+// keep the wording they were given in.
+
+// ErrVersionNotFound is returned for form versions that don't exist or haven't been published
+var ErrVersionNotFound = errors.New("form version not found")
+
+// draft is a form's draft version, with the IDs of sections, elements and options copied
+// into it from the version it was created from
+type draft struct {
+	versionID int64
+	sections  map[int64]int64
+	elements  map[int64]int64
+	options   map[int64]int64
+}
+
+func insertVersion(formID int64, version int, publishedAt *time.Time, db Querier) (int64, error) {
+	resp, err := db.Exec("INSERT INTO form_versions (formID, version, publishedAt, createdAt) VALUES (?, ?, ?, ?)", formID, version, publishedAt, time.Now())
+	if err != nil {
+		return 0, errors.New("failed to insert form version: " + err.Error())
+	}
+	id, err := resp.LastInsertId()
+	if err != nil {
+		return 0, errors.New("failed to get inserted form version id: " + err.Error())
+	}
+	return id, nil
+}
+
+// getDraft returns the form's draft version, copying the latest version into a new draft if
+// every version has been published
+func getDraft(formID int64, db Querier) (*draft, error) {
+	var versionID int64
+	var version int
+	var publishedAt sql.NullTime
+	err := db.QueryRow("SELECT id, version, publishedAt FROM form_versions WHERE formID = ? ORDER BY version DESC LIMIT 1 FOR UPDATE", formID).Scan(&versionID, &version, &publishedAt)
+	if err != nil {
+		return nil, errors.New("failed to get form version: " + err.Error())
+	}
+	if !publishedAt.Valid {
+		return &draft{versionID: versionID}, nil
+	}
+
+	d := draft{sections: map[int64]int64{}, elements: map[int64]int64{}, options: map[int64]int64{}}
+	d.versionID, err = insertVersion(formID, version+1, nil, db)
+	if err != nil {
+		return nil, err
+	}
+	sections, err := getVersionSections(versionID, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range sections {
+		publishedID := section.ID
+		section.VersionID = d.versionID
+		_, err := NewSection(section, db)
+		if err != nil {
+			return nil, errors.New("failed to copy section: " + err.Error())
+		}
+		d.sections[publishedID] = section.ID
+	}
+	elements, err := getVersionElements(versionID, db)
+	if err != nil {
+		return nil, err
+	}
+	for _, element := range elements {
+		publishedID := element.ID
+		publishedOptions := make([]int64, len(element.Options))
+		for i, option := range element.Options {
+			publishedOptions[i] = option.ID
+		}
+		element.VersionID = d.versionID
+		if element.SectionID != 0 {
+			element.SectionID = d.sections[element.SectionID]
+		}
+		_, err := NewElement(element, db)
+		if err != nil {
+			return nil, errors.New("failed to copy element: " + err.Error())
+		}
+		d.elements[publishedID] = element.ID
+		for i, option := range element.Options {
+			d.options[publishedOptions[i]] = option.ID
+		}
+	}
+	return &d, nil
+}
+
+// PublishForm freezes the form's draft and makes it the current version
+func PublishForm(id int64, db *sql.DB) (*Form, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, errors.New("failed to start transaction: " + err.Error())
+	}
+	defer tx.Rollback()
+	err = lockForm(id, tx)
+	if err != nil {
+		return nil, err
+	}
+	var versionID int64
+	err = tx.QueryRow("SELECT id FROM form_versions WHERE formID = ? AND publishedAt IS NULL FOR UPDATE", id).Scan(&versionID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: form %v has no draft to publish", ErrInvalidForm, id)
+	}
+	if err != nil {
+		return nil, errors.New("failed to get form draft: " + err.Error())
+	}
+	_, err = tx.Exec("UPDATE form_versions SET publishedAt = ? WHERE id = ?", time.Now(), versionID)
+	if err != nil {
+		return nil, errors.New("failed to publish form version: " + err.Error())
+	}
+	_, err = tx.Exec("UPDATE forms SET currentVersionID = ? WHERE id = ?", versionID, id)
+	if err != nil {
+		return nil, errors.New("failed to set current form version: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("failed to commit form version: " + err.Error())
+	}
+	return GetForm(id, false, db)
+}
+
+// GetFormVersion returns a published version of a form
+func GetFormVersion(id int64, version int, db *sql.DB) (*Form, error) {
+	var form Form
+	err := db.QueryRow("SELECT id, name, required, live FROM forms WHERE id = ?", id).Scan(&form.ID, &form.Name, &form.Required, &form.Live)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: form %v does not exist", ErrVersionNotFound, id)
+	}
+	if err != nil {
+		return nil, errors.New("failed to get form: " + err.Error())
+	}
+	var versionID int64
+	err = db.QueryRow("SELECT id FROM form_versions WHERE formID = ? AND version = ? AND publishedAt IS NOT NULL", id, version).Scan(&versionID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: form %v has no published version %v", ErrVersionNotFound, id, version)
+	}
+	if err != nil {
+		return nil, errors.New("failed to get form version: " + err.Error())
+	}
+	err = loadVersion(&form, versionID, db)
+	if err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+// GetCurrentVersion returns the current published version of a form, the one it is answered
+// with, whether or not the form is live. Archived elements are kept, since they may have answers.
+func GetCurrentVersion(id int64, db *sql.DB) (*Form, error) {
+	var form Form
+	var currentVersionID sql.NullInt64
+	err := db.QueryRow("SELECT id, name, required, live, currentVersionID FROM forms WHERE id = ? AND deletedAt IS NULL", id).Scan(&form.ID, &form.Name, &form.Required, &form.Live, &currentVersionID)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: form %v", ErrFormNotFound, id)
+	}
+	if err != nil {
+		return nil, errors.New("failed to get form: " + err.Error())
+	}
+	if !currentVersionID.Valid {
+		return nil, fmt.Errorf("%w: form %v has not been published", ErrFormNotFound, id)
+	}
+	err = loadVersion(&form, currentVersionID.Int64, db)
+	if err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+// AnswerableVersion returns the version number of the element's form version, which must be
+// the current published version for the element to be answered
+func AnswerableVersion(element *Element, db Querier) (int, error) {
+	var version int
+	var currentVersionID sql.NullInt64
+	var deletedAt sql.NullTime
+	err := db.QueryRow("SELECT v.version, f.currentVersionID, f.deletedAt FROM form_versions v, forms f WHERE v.formID = f.id AND v.id = ?", element.VersionID).Scan(&version, &currentVersionID, &deletedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get version of element %v: %s", element.ID, err.Error())
+	}
+	if deletedAt.Valid {
+		return 0, answerError("this form has been deleted")
+	}
+	if currentVersionID.Int64 != element.VersionID {
+		return 0, answerError("this question is not on the current version of the form")
+	}
+	return version, nil
+}
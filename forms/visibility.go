@@ -0,0 +1,43 @@
+package forms
+
+import "fmt"
+
+// Element visibilities decide who can see approved answers to an element on public outputs
+// such as provider profiles. They don't affect who is asked the question; see ShowIf for that.
+// Admin endpoints and the user who gave an answer always see it. Answers to sensitive elements
+// are never shown on public outputs, whatever their visibility.
+const (
+	VisibilityPublic    = "public"    // anyone
+	VisibilityProviders = "providers" // approved providers
+	VisibilityAdmin     = "admin"     // admins only
+)
+
+var visibilities = map[string][]string{
+	VisibilityPublic:    {VisibilityPublic},
+	VisibilityProviders: {VisibilityPublic, VisibilityProviders},
+	VisibilityAdmin:     {VisibilityPublic, VisibilityProviders, VisibilityAdmin},
+}
+
+// VisibleTo returns the element visibilities an audience (one of the visibilities) can see
+func VisibleTo(audience string) []string {
+	visible, ok := visibilities[audience]
+	if !ok {
+		return visibilities[VisibilityPublic]
+	}
+	return visible
+}
+
+// validateVisibility defaults the element to public. Searchable elements must be public because
+// the provider directory shows and filters on their answers.
+func validateVisibility(element *Element) error {
+	if element.Visibility == "" {
+		element.Visibility = VisibilityPublic
+	}
+	if _, ok := visibilities[element.Visibility]; !ok {
+		return fmt.Errorf("unknown visibility %q", element.Visibility)
+	}
+	if element.Search && element.Visibility != VisibilityPublic {
+		return fmt.Errorf("searchable elements must be %s", VisibilityPublic)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+//go:build ignore
+// +build ignore
+
+// gen_zipcodes writes zipcodes.txt from the Census Bureau ZCTA gazetteer file, keeping only
+// the columns LookupZip reads. Run it with go generate ./geo, or pass the path or URL of a
+// gazetteer file (zipped or not) to use another year.
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const defaultGazetteer = "https://www2.census.gov/geo/docs/maps-data/data/gazetteer/2023_Gazetteer/2023_Gaz_zcta_national.zip"
+
+func main() {
+	source := defaultGazetteer
+	if len(os.Args) > 1 {
+		source = os.Args[1]
+	}
+	data, err := read(source)
+	if err != nil {
+		log.Fatal("Failed to read gazetteer: " + err.Error())
+	}
+	if strings.HasSuffix(source, ".zip") {
+		data, err = unzip(data)
+		if err != nil {
+			log.Fatal("Failed to unzip gazetteer: " + err.Error())
+		}
+	}
+	out, err := os.Create("zipcodes.txt")
+	if err != nil {
+		log.Fatal("Failed to create zipcodes.txt: " + err.Error())
+	}
+	defer out.Close()
+	count, err := trim(bytes.NewReader(data), out)
+	if err != nil {
+		log.Fatal("Failed to write zipcodes.txt: " + err.Error())
+	}
+	log.Printf("Wrote %d zip codes", count)
+}
+
+func read(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+	resp, err := http.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", source, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// unzip returns the first text file in a zip archive
+func unzip(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range archive.File {
+		if !strings.HasSuffix(f.Name, ".txt") {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	return nil, errors.New("no .txt file in the archive")
+}
+
+// trim copies the GEOID, INTPTLAT and INTPTLONG columns of the gazetteer
+func trim(in io.Reader, out io.Writer) (int, error) {
+	scanner := bufio.NewScanner(in)
+	if !scanner.Scan() {
+		return 0, errors.New("gazetteer is empty")
+	}
+	columns := map[string]int{}
+	for i, name := range strings.Split(scanner.Text(), "\t") {
+		columns[strings.TrimSpace(name)] = i
+	}
+	zipCol, ok := columns["GEOID"]
+	latCol, hasLat := columns["INTPTLAT"]
+	lngCol, hasLng := columns["INTPTLONG"]
+	if !ok || !hasLat || !hasLng {
+		return 0, errors.New("gazetteer is missing GEOID, INTPTLAT or INTPTLONG")
+	}
+	w := bufio.NewWriter(out)
+	fmt.Fprintln(w, "GEOID\tINTPTLAT\tINTPTLONG")
+	count := 0
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) <= zipCol || len(fields) <= latCol || len(fields) <= lngCol {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", strings.TrimSpace(fields[zipCol]), strings.TrimSpace(fields[latCol]), strings.TrimSpace(fields[lngCol]))
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
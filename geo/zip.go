@@ -0,0 +1,99 @@
+// Package geo resolves zip codes to coordinates without calling an external service
+package geo
+
+import (
+	_ "embed"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const earthRadiusMiles = 3958.8
+
+// zipcodes.txt uses the column layout of the Census Bureau ZCTA gazetteer file
+// (GEOID, INTPTLAT and INTPTLONG; other columns are ignored). The checked in copy is
+// a sample of Colorado zip codes; go generate ./geo replaces it with the national
+// gazetteer file, see gen_zipcodes.go.
+//go:generate go run gen_zipcodes.go
+//go:embed zipcodes.txt
+var zipData string
+
+var (
+	loadZips sync.Once
+	zips     map[string]Point
+	zipErr   error
+)
+
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+func parseZips(data string) (map[string]Point, error) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if len(lines) == 0 {
+		return nil, errors.New("zip code data is empty")
+	}
+	columns := map[string]int{}
+	for i, name := range strings.Split(lines[0], "\t") {
+		columns[strings.TrimSpace(name)] = i
+	}
+	zipCol, ok := columns["GEOID"]
+	latCol, hasLat := columns["INTPTLAT"]
+	lngCol, hasLng := columns["INTPTLONG"]
+	if !ok || !hasLat || !hasLng {
+		return nil, errors.New("zip code data is missing GEOID, INTPTLAT or INTPTLONG")
+	}
+	points := map[string]Point{}
+	for _, line := range lines[1:] {
+		fields := strings.Split(line, "\t")
+		if len(fields) <= zipCol || len(fields) <= latCol || len(fields) <= lngCol {
+			continue
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(fields[latCol]), 64)
+		if err != nil {
+			return nil, errors.New("invalid latitude for zip " + fields[zipCol])
+		}
+		lng, err := strconv.ParseFloat(strings.TrimSpace(fields[lngCol]), 64)
+		if err != nil {
+			return nil, errors.New("invalid longitude for zip " + fields[zipCol])
+		}
+		points[strings.TrimSpace(fields[zipCol])] = Point{Lat: lat, Lng: lng}
+	}
+	return points, nil
+}
+
+// LookupZip returns the centroid of a 5 digit zip code. ZIP+4 codes are accepted.
+func LookupZip(zip string) (Point, bool) {
+	loadZips.Do(func() {
+		zips, zipErr = parseZips(zipData)
+	})
+	if zipErr != nil {
+		return Point{}, false
+	}
+	zip = strings.TrimSpace(zip)
+	if len(zip) > 5 {
+		zip = zip[:5]
+	}
+	point, ok := zips[zip]
+	return point, ok
+}
+
+// DistanceMiles returns the great-circle distance between two points
+func DistanceMiles(a Point, b Point) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(h))
+}
+
+// BoundingBox returns the corners of a box that contains every point within radius miles of p
+func BoundingBox(p Point, radius float64) (min Point, max Point) {
+	latDelta := radius / 69.0
+	lngDelta := radius / (69.0 * math.Cos(p.Lat*math.Pi/180))
+	return Point{Lat: p.Lat - latDelta, Lng: p.Lng - lngDelta}, Point{Lat: p.Lat + latDelta, Lng: p.Lng + lngDelta}
+}
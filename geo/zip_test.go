@@ -0,0 +1,52 @@
+package geo_test
+
+import (
+	"api/geo"
+	"math"
+	"testing"
+)
+
+func TestLookupZip(t *testing.T) {
+	point, ok := geo.LookupZip("80302")
+	if !ok {
+		t.Error("expected 80302 to be found")
+		return
+	}
+	if point.Lat < 39 || point.Lat > 41 || point.Lng < -106 || point.Lng > -105 {
+		t.Error("expected 80302 to be near Boulder; got", point)
+	}
+	if _, ok := geo.LookupZip("80302-1234"); !ok {
+		t.Error("expected ZIP+4 code to be found")
+	}
+	if _, ok := geo.LookupZip("00000"); ok {
+		t.Error("expected unknown zip to not be found")
+	}
+}
+
+func TestDistanceMiles(t *testing.T) {
+	boulder := geo.Point{Lat: 40.0150, Lng: -105.2705}
+	denver := geo.Point{Lat: 39.7392, Lng: -104.9903}
+	distance := geo.DistanceMiles(boulder, denver)
+	// Boulder is about 24 miles from downtown Denver
+	if math.Abs(distance-24) > 2 {
+		t.Error("expected about 24 miles from Boulder to Denver; got", distance)
+	}
+	if geo.DistanceMiles(boulder, boulder) != 0 {
+		t.Error("expected no distance between the same point")
+	}
+}
+
+func TestBoundingBox(t *testing.T) {
+	center := geo.Point{Lat: 40.0150, Lng: -105.2705}
+	min, max := geo.BoundingBox(center, 25)
+	for _, p := range []geo.Point{
+		{Lat: center.Lat, Lng: min.Lng},
+		{Lat: center.Lat, Lng: max.Lng},
+		{Lat: min.Lat, Lng: center.Lng},
+		{Lat: max.Lat, Lng: center.Lng},
+	} {
+		if distance := geo.DistanceMiles(center, p); distance < 24.5 {
+			t.Error("expected bounding box edge to be at least 25 miles away; got", distance)
+		}
+	}
+}
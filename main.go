@@ -2,6 +2,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -95,7 +96,7 @@ func setup() *env.Env {
 			})
 			return
 		}
-		_, err = event.SaveResponse(environment.DB)
+		_, err = event.SaveResponse(environment.Keys, environment.DB)
 		if err != nil {
 			fmt.Println("Failed to save response: " + err.Error())
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -144,7 +145,7 @@ func setup() *env.Env {
 		})
 	})
 
-	environment.Router.GET("/responses/tally/:id", func(c *gin.Context) {
+	environment.Router.GET("/responses/tally/:id", requirePermission(environment, users.PermResponsesRead), func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			msg := "Failed to parse int64: " + err.Error()
@@ -154,7 +155,7 @@ func setup() *env.Env {
 			})
 			return
 		}
-		responses, err := tally.GetPrettyResponse(id, environment.DB)
+		responses, err := tally.GetPrettyResponse(id, environment.Keys, environment.DB)
 		if err != nil {
 			msg := "Failed to get responses: " + err.Error()
 			fmt.Println(msg)
@@ -169,39 +170,66 @@ func setup() *env.Env {
 	})
 
 	environment.Router.GET("/providers", func(c *gin.Context) {
-		providers, err := users.GetApprovedProviders(environment.DB)
+		var query users.ProviderQuery
+		err := c.ShouldBindQuery(&query)
 		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		query.Attributes = c.QueryMap("attr")
+		page, err := users.SearchProviders(&query, environment.DB)
+		if err != nil {
+			if errors.Is(err, users.ErrProviderQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"providers": providers,
+			"providers":   page.Providers,
+			"total":       page.Total,
+			"next_cursor": page.NextCursor,
 		})
 	})
 
-	environment.Router.GET("/provider/:id", func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": err.Error(),
-			})
-			return
+	environment.Router.GET("/providers/near", func(c *gin.Context) {
+		var radius float64
+		if c.Query("radius") != "" {
+			var err error
+			radius, err = strconv.ParseFloat(c.Query("radius"), 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": "radius must be a number. " + err.Error(),
+				})
+				return
+			}
 		}
-		provider, err := users.GetApprovedProvider(&id, environment.DB)
+		providers, err := users.GetProvidersNear(c.Query("zip"), radius, environment.DB)
 		if err != nil {
+			if errors.Is(err, users.ErrProviderQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"provider": provider,
+			"providers": providers,
 		})
 	})
 
-	environment.Router.GET("/provider/:id/responses", func(c *gin.Context) {
+	environment.Router.GET("/provider/:id", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -209,18 +237,25 @@ func setup() *env.Env {
 			})
 			return
 		}
-		resps, err := responses.GetApprovedResponsesByProvider(id, environment.DB)
+		provider, err := users.GetApprovedProvider(&id, environment.DB)
 		if err != nil {
+			if errors.Is(err, users.ErrProviderNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
+			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"responses": resps,
+			"provider": provider,
 		})
 	})
 
-	environment.Router.GET("/provider/:id/responses/all", adminAuthRequired(environment), func(c *gin.Context) {
+	environment.Router.GET("/provider/:id/profile", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -228,17 +263,45 @@ func setup() *env.Env {
 			})
 			return
 		}
-		resps, err := responses.GetResponsesByProvider(id, environment.DB)
+		profile, err := responses.GetProviderProfile(id, viewerAudience(c, environment), environment.DB)
 		if err != nil {
+			if errors.Is(err, users.ErrProviderNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
+			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"responses": resps,
+			"profile": profile,
 		})
 	})
 
+	environment.Router.GET("/provider/:id/responses", listResponses(environment, func(c *gin.Context, filter *responses.ResponseFilter) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		approved := true
+		filter.UserID = id
+		filter.Approved = &approved
+		filter.Audience = viewerAudience(c, environment)
+		return nil
+	}))
+
+	environment.Router.GET("/provider/:id/responses/all", requirePermission(environment, users.PermResponsesRead), listResponses(environment, func(c *gin.Context, filter *responses.ResponseFilter) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		filter.UserID = id
+		return nil
+	}))
+
 	authorizedUser := environment.Router.Group("/user", authRequired(environment))
 	authorizedUser.PUT("", func(c *gin.Context) {
 		users.UpdateUserHandler(c, environment)
@@ -246,7 +309,7 @@ func setup() *env.Env {
 	authorizedUser.GET("", func(c *gin.Context) {
 		users.GetUserHandler(c, environment)
 	})
-	authorizedUser.GET("/:id", adminAuthRequired(environment), func(c *gin.Context) {
+	authorizedUser.GET("/:id", requirePermission(environment, users.PermUsersRead), func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -286,7 +349,7 @@ func setup() *env.Env {
 		})
 	})
 
-	adminUsers := environment.Router.Group("/users", adminAuthRequired(environment))
+	adminUsers := environment.Router.Group("/users", requirePermission(environment, users.PermUsersRead))
 	adminUsers.GET("", func(c *gin.Context) {
 		foundUsers, err := users.GetUsers(environment.DB)
 		if err != nil {
@@ -299,22 +362,73 @@ func setup() *env.Env {
 			"users": foundUsers,
 		})
 	})
-
-	unauthorizedForms := environment.Router.Group("/forms")
-	unauthorizedForms.GET("", func(c *gin.Context) {
-		foundForms, err := forms.GetLiveForms(environment.DB)
+	authorizedUser.PUT("/:id/role/:role/active/:active", requirePermission(environment, users.PermUsersWrite), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		roleID, err := strconv.ParseInt(c.Param("role"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		active, err := strconv.ParseBool(c.Param("active"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		err = checkRoleGrant(c, roleID, environment)
+		if err != nil {
+			return
+		}
+		err = users.SetUserRoleActive(id, roleID, active, environment)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"forms": foundForms,
-		})
+		c.Status(http.StatusOK)
 	})
-	unauthorizedForms.GET("/all", adminAuthRequired(environment), func(c *gin.Context) {
-		foundForms, err := forms.GetForms(environment.DB)
+	authorizedUser.DELETE("/:id/role/:role", requirePermission(environment, users.PermUsersWrite), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		roleID, err := strconv.ParseInt(c.Param("role"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		err = checkRoleGrant(c, roleID, environment)
+		if err != nil {
+			return
+		}
+		err = users.RevokeUserRole(id, roleID, environment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+
+	adminRoles := environment.Router.Group("/roles", requirePermission(environment, users.PermUsersRead))
+	adminRoles.GET("", func(c *gin.Context) {
+		roles, err := users.GetRoles(environment.DB)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -322,12 +436,11 @@ func setup() *env.Env {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"forms": foundForms,
+			"roles": roles,
 		})
 	})
-	unauthorizedForms.GET("/responses", func(c *gin.Context) {
-		token := c.Request.Header.Get("Authorization")
-		formResps, err := responses.GetFormResponsesByToken(token, environment)
+	adminRoles.GET("/requests", func(c *gin.Context) {
+		requests, err := users.GetRoleRequests(environment.DB)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -335,27 +448,21 @@ func setup() *env.Env {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"form_responses": formResps,
+			"requests": requests,
 		})
 	})
 
-	form := environment.Router.Group("/form")
-	form.GET("/:id", func(c *gin.Context) {
-		forms.GetFormHandler(c, true, environment.DB)
-	})
-	form.GET("/any/:id", adminAuthRequired(environment), func(c *gin.Context) {
-		forms.GetFormHandler(c, false, environment.DB)
-	})
-	form.GET("/:id/responses", authRequired(environment), func(c *gin.Context) {
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	adminRole := environment.Router.Group("/role", requirePermission(environment, users.PermRolesWrite))
+	adminRole.POST("", func(c *gin.Context) {
+		var role users.Role
+		err := c.ShouldBindJSON(&role)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		token := c.Request.Header.Get("Authorization")
-		resps, err := responses.GetResponsesByFormAndToken(id, token, environment)
+		newRole, err := users.NewRole(&role, environment.DB)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -363,10 +470,10 @@ func setup() *env.Env {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"responses": resps,
+			"role": newRole,
 		})
 	})
-	form.GET("/:id/responses/all", adminAuthRequired(environment), func(c *gin.Context) {
+	adminRole.DELETE("/:id", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -374,25 +481,25 @@ func setup() *env.Env {
 			})
 			return
 		}
-		resps, err := responses.GetResponsesByForm(id, environment.DB)
+		err = users.DeleteRole(id, environment)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"responses": resps})
+		c.Status(http.StatusOK)
 	})
-	form.POST("", adminAuthRequired(environment), func(c *gin.Context) {
-		var form forms.Form
-		err := c.ShouldBindJSON(&form)
+
+	adminRole.GET("/:id/permissions", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		newForm, err := forms.NewForm(&form, environment.DB)
+		permissions, err := users.GetRolePermissions(id, environment.DB)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -400,19 +507,25 @@ func setup() *env.Env {
 			return
 		}
 		c.JSON(http.StatusOK, gin.H{
-			"form": newForm,
+			"permissions": permissions,
 		})
 	})
-	form.PUT("", adminAuthRequired(environment), func(c *gin.Context) {
-		var form forms.Form
-		err := c.ShouldBindJSON(&form)
+	adminRole.PUT("/:id/permission/:permission", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		err = forms.UpdateForm(&form, environment.DB)
+		permission := c.Param("permission")
+		if !users.ValidPermission(permission) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "unknown permission " + permission,
+			})
+			return
+		}
+		err = users.GrantPermission(id, permission, environment)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
@@ -421,66 +534,94 @@ func setup() *env.Env {
 		}
 		c.Status(http.StatusOK)
 	})
-	form.DELETE("/:id", adminAuthRequired(environment), func(c *gin.Context) {
+	adminRole.DELETE("/:id/permission/:permission", func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		err = forms.DeleteForm(id, environment.DB)
+		err = users.RevokePermission(id, c.Param("permission"), environment)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
+		c.Status(http.StatusOK)
 	})
 
-	authorizedResponse := environment.Router.Group("/response", authRequired(environment))
-	authorizedResponse.POST("", func(c *gin.Context) {
-		var response responses.Response
-		err := c.ShouldBindJSON(&response)
+	environment.Router.GET("/permissions", requirePermission(environment, users.PermUsersRead), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"permissions": users.Permissions,
+		})
+	})
+
+	unauthorizedForms := environment.Router.Group("/forms")
+	unauthorizedForms.GET("", func(c *gin.Context) {
+		foundForms, err := forms.GetLiveForms(environment.DB)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
+			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-
-		// get user ID from session token
-		user, err := users.GetUserBySession(c.Request.Header.Get("Authorization"), environment)
+		c.JSON(http.StatusOK, gin.H{
+			"forms": foundForms,
+		})
+	})
+	unauthorizedForms.GET("/all", requirePermission(environment, users.PermFormsRead), func(c *gin.Context) {
+		foundForms, err := forms.GetForms(environment.DB)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
+			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-
-		var resp *responses.Response
-		// NOTE: potential problem here because someone could pass both option IDs and a value.
-		// If Option IDs are passed, any value passed will not be stored.
-		if response.OptionIDs != nil {
-			resp, err = responses.NewResponseWithOptions(response.ElementID, user.ID, response.OptionIDs, environment.DB)
-		} else {
-			resp, err = responses.NewResponse(response.ElementID, user.ID, response.Value, environment.DB)
-		}
+		c.JSON(http.StatusOK, gin.H{
+			"forms": foundForms,
+		})
+	})
+	unauthorizedForms.GET("/responses", func(c *gin.Context) {
+		token := c.Request.Header.Get("Authorization")
+		formResps, err := responses.GetFormResponsesByToken(token, environment)
 		if err != nil {
-			if err.Error() == "user must accept the user agreement" {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": err.Error(),
-				})
-				return
-			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"response": resp})
+		c.JSON(http.StatusOK, gin.H{
+			"form_responses": formResps,
+		})
 	})
-	authorizedResponse.GET("/:id", func(c *gin.Context) {
+
+	form := environment.Router.Group("/form")
+	form.GET("/:id", func(c *gin.Context) {
+		forms.GetFormHandler(c, true, environment.DB)
+	})
+	form.GET("/any/:id", requirePermission(environment, users.PermFormsRead), func(c *gin.Context) {
+		forms.GetFormHandler(c, false, environment.DB)
+	})
+	form.GET("/:id/responses", authRequired(environment), listResponses(environment, func(c *gin.Context, filter *responses.ResponseFilter) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		filter.FormID = id
+		filter.UserID = c.GetInt64("user_id")
+		return nil
+	}))
+	form.GET("/:id/responses/all", requirePermission(environment, users.PermResponsesRead), listResponses(environment, func(c *gin.Context, filter *responses.ResponseFilter) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return err
+		}
+		filter.FormID = id
+		return nil
+	}))
+	form.GET("/:id/responses/export", requirePermission(environment, users.PermResponsesRead), func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -488,105 +629,778 @@ func setup() *env.Env {
 			})
 			return
 		}
-		response, err := responses.GetResponse(id, environment.DB)
+		var filter responses.ExportFilter
+		err = c.ShouldBindQuery(&filter)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		// check user owns the response
-		userID, userIDExists := c.Get("user_id")
-		if userIDExists {
-			if userID != response.UserID {
-				c.JSON(http.StatusUnauthorized, gin.H{
-					"error": "User does not own response",
+		export, err := responses.PrepareExport(id, &filter, environment.Keys, environment.DB)
+		if err != nil {
+			if errors.Is(err, responses.ErrResponseQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
 				})
 				return
 			}
-		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Unable to get user ID from context",
+			if errors.Is(err, forms.ErrFormNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
 			})
 			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{"response": response})
+		c.Header("Content-Type", export.ContentType())
+		c.Header("Content-Disposition", `attachment; filename="`+export.Filename()+`"`)
+		c.Status(http.StatusOK)
+		err = export.Write(c.Writer)
+		if err != nil {
+			// the status has already been sent, so all that's left is to cut the export short
+			log.Println("Failed to export responses: " + err.Error())
+			c.Abort()
+		}
 	})
-	authorizedResponse.PUT("/:id/approve/:approval", adminAuthRequired(environment), func(c *gin.Context) {
-		approval, err := strconv.ParseBool(c.Param("approval"))
+	form.POST("/:id/submission", authRequired(environment), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		var submission responses.Submission
+		err = c.ShouldBindJSON(&submission)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		err = responses.ApproveResponse(id, approval, environment.DB)
+		resps, err := responses.SubmitForm(id, c.GetInt64("user_id"), &submission, environment.Keys, environment.DB)
 		if err != nil {
+			if errors.Is(err, forms.ErrFormNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			var invalid responses.ValidationErrors
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  err.Error(),
+					"errors": invalid,
+				})
+				return
+			}
+			if err.Error() == "user must accept the user agreement" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.Status(http.StatusOK)
+		c.JSON(http.StatusOK, gin.H{"responses": resps})
 	})
-	authorizedResponse.GET("/any/:id", adminAuthRequired(environment), func(c *gin.Context) {
+	form.POST("/:id/sections/:section/submission", authRequired(environment), func(c *gin.Context) {
 		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
+			return
 		}
-		resp, err := responses.GetResponse(id, environment.DB)
+		// the implicit section of a form without sections is 0
+		sectionID, err := strconv.ParseInt(c.Param("section"), 10, 64)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"response": resp})
-	})
-
-	authorizedResponses := environment.Router.Group("/responses", authRequired(environment))
-	authorizedResponses.GET("", func(c *gin.Context) {
-		resps, err := responses.GetResponses(environment.DB)
+		var submission responses.Submission
+		err = c.ShouldBindJSON(&submission)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
+			c.JSON(http.StatusBadRequest, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		userID, userIDExists := c.Get("user_id")
-		var userResps []*responses.Response
-		if userIDExists {
-			for _, resp := range resps {
-				if resp.UserID == userID {
-					userResps = append(userResps, resp)
-				}
-			}
-		}
-		c.JSON(http.StatusOK, gin.H{"responses": userResps})
-	})
-	authorizedResponses.GET("/all", adminAuthRequired(environment), func(c *gin.Context) {
-		resps, err := responses.GetResponses(environment.DB)
+		resps, err := responses.SubmitSection(id, sectionID, c.GetInt64("user_id"), &submission, environment.Keys, environment.DB)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": err.Error(),
-			})
+			if errors.Is(err, forms.ErrFormNotFound) || errors.Is(err, forms.ErrSectionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			var invalid responses.ValidationErrors
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  err.Error(),
+					"errors": invalid,
+				})
+				return
+			}
+			if err.Error() == "user must accept the user agreement" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		progress, err := responses.GetFormProgress(id, c.GetInt64("user_id"), environment.Keys, environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"responses": resps, "progress": progress})
+	})
+	form.GET("/:id/progress", authRequired(environment), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		progress, err := responses.GetFormProgress(id, c.GetInt64("user_id"), environment.Keys, environment.DB)
+		if errors.Is(err, forms.ErrFormNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"progress": progress})
+	})
+	form.GET("/:id/draft", authRequired(environment), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		draft, err := responses.GetDraft(id, c.GetInt64("user_id"), environment.Keys, environment.DB)
+		if errors.Is(err, forms.ErrFormNotFound) || errors.Is(err, responses.ErrDraftNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"draft": draft})
+	})
+	// autosave: answers are merged into the draft without checking they are complete
+	form.PUT("/:id/draft", authRequired(environment), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		var submission responses.Submission
+		err = c.ShouldBindJSON(&submission)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		draft, err := responses.SaveDraft(id, c.GetInt64("user_id"), submission.Answers, environment.DraftTTL, environment.Keys, environment.DB)
+		if err != nil {
+			if errors.Is(err, forms.ErrFormNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			var invalid responses.ValidationErrors
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  err.Error(),
+					"errors": invalid,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"draft": draft})
+	})
+	form.POST("/:id/draft/submit", authRequired(environment), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		resps, err := responses.SubmitDraft(id, c.GetInt64("user_id"), environment.Keys, environment.DB)
+		if err != nil {
+			if errors.Is(err, forms.ErrFormNotFound) || errors.Is(err, responses.ErrDraftNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			var invalid responses.ValidationErrors
+			if errors.As(err, &invalid) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":  err.Error(),
+					"errors": invalid,
+				})
+				return
+			}
+			if err.Error() == "user must accept the user agreement" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"responses": resps})
+	})
+	form.DELETE("/:id/draft", authRequired(environment), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		err = responses.DeleteDraft(id, c.GetInt64("user_id"), environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	form.POST("", requirePermission(environment, users.PermFormsWrite), func(c *gin.Context) {
+		var form forms.Form
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		newForm, err := forms.NewForm(&form, environment.DB)
+		if err != nil {
+			if errors.Is(err, forms.ErrInvalidForm) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"form": newForm,
+		})
+	})
+	form.PUT("", requirePermission(environment, users.PermFormsWrite), func(c *gin.Context) {
+		var form forms.Form
+		err := c.ShouldBindJSON(&form)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		diff, err := forms.UpdateForm(&form, environment.DB)
+		if err != nil {
+			if errors.Is(err, forms.ErrFormNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, forms.ErrInvalidForm) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"changes": diff})
+	})
+	form.PUT("/:id/publish", requirePermission(environment, users.PermFormsWrite), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		published, err := forms.PublishForm(id, environment.DB)
+		if err != nil {
+			if errors.Is(err, forms.ErrFormNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, forms.ErrInvalidForm) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// searchable elements may have changed
+		err = responses.ReindexForm(id, environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"form": published})
+	})
+	form.GET("/:id/versions/:version", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		found, err := forms.GetFormVersion(id, version, environment.DB)
+		if err != nil {
+			if errors.Is(err, forms.ErrVersionNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"form": found})
+	})
+	form.DELETE("/:id", requirePermission(environment, users.PermFormsWrite), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// answered forms are kept unless the caller asks to delete their responses too
+		purge := c.Query("purge") == "true"
+		deletion, err := forms.DeleteForm(id, purge, environment.DB)
+		if errors.Is(err, forms.ErrFormNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// drop the form's answers from provider search
+		err = responses.ReindexForm(id, environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"deletion": deletion})
+	})
+
+	authorizedResponse := environment.Router.Group("/response", authRequired(environment))
+	authorizedResponse.POST("", func(c *gin.Context) {
+		var response responses.Response
+		err := c.ShouldBindJSON(&response)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		// get user ID from session token
+		user, err := users.GetUserBySession(c.Request.Header.Get("Authorization"), environment)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		if response.OptionIDs != nil && response.Value != "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "send either option_ids or a value, not both",
+			})
+			return
+		}
+		var resp *responses.Response
+		if response.OptionIDs != nil {
+			resp, err = responses.NewResponseWithOptions(response.ElementID, user.ID, response.OptionIDs, environment.DB)
+		} else {
+			resp, err = responses.NewResponse(response.ElementID, user.ID, response.Value, environment.Keys, environment.DB)
+		}
+		if err != nil {
+			if err.Error() == "user must accept the user agreement" {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, forms.ErrInvalidAnswer) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"response": resp})
+	})
+	authorizedResponse.GET("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		response, err := responses.GetResponse(id, environment.Keys, environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// check user owns the response
+		userID, userIDExists := c.Get("user_id")
+		if userIDExists {
+			if userID != response.UserID {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "User does not own response",
+				})
+				return
+			}
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Unable to get user ID from context",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"response": response})
+	})
+	authorizedResponse.PUT("/:id", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		var answer responses.Answer
+		err = c.ShouldBindJSON(&answer)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		resp, err := responses.UpdateResponse(id, c.GetInt64("user_id"), answer.Value, answer.OptionIDs, environment.Keys, environment.DB)
+		if err != nil {
+			if errors.Is(err, responses.ErrResponseNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, responses.ErrNotResponseOwner) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, forms.ErrInvalidAnswer) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"response": resp})
+	})
+	authorizedResponse.GET("/:id/revisions", requirePermission(environment, users.PermResponsesRead), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		history, err := responses.GetResponseHistory(id, environment.Keys, environment.DB)
+		if errors.Is(err, responses.ErrResponseNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"history": history})
+	})
+	authorizedResponse.POST("/:id/comments", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"responses": resps})
+		var request struct {
+			Body string `json:"body"`
+		}
+		err = c.ShouldBindJSON(&request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		user, err := users.GetUserBySession(c.Request.Header.Get("Authorization"), environment)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		comment, err := responses.AddComment(id, user.ID, user.HasPermission(users.PermResponsesApprove), request.Body, environment.DB)
+		if err != nil {
+			respondReviewError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"comment": comment})
+	})
+	authorizedResponse.POST("/:id/resubmit", func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		var request struct {
+			Comment string `json:"comment"`
+		}
+		// the comment is optional, so an empty body is fine
+		if c.Request.ContentLength != 0 {
+			err = c.ShouldBindJSON(&request)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		resp, err := responses.ResubmitResponse(id, c.GetInt64("user_id"), request.Comment, environment.Keys, environment.DB)
+		if err != nil {
+			respondReviewError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"response": resp})
+	})
+	authorizedResponse.PUT("/:id/approve/:approval", requirePermission(environment, users.PermResponsesApprove), func(c *gin.Context) {
+		approval, err := strconv.ParseBool(c.Param("approval"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		err = responses.ApproveResponse(id, approval, c.GetInt64("user_id"), environment.DB)
+		if err != nil {
+			if errors.Is(err, responses.ErrResponseNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	authorizedResponse.GET("/any/:id", requirePermission(environment, users.PermResponsesRead), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		}
+		resp, err := responses.GetResponse(id, environment.Keys, environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"response": resp})
+	})
+
+	authorizedResponses := environment.Router.Group("/responses", authRequired(environment))
+	authorizedResponses.GET("", listResponses(environment, func(c *gin.Context, filter *responses.ResponseFilter) error {
+		filter.UserID = c.GetInt64("user_id")
+		return nil
+	}))
+	authorizedResponses.GET("/all", requirePermission(environment, users.PermResponsesRead), listResponses(environment, nil))
+
+	moderation := environment.Router.Group("/moderation", requirePermission(environment, users.PermResponsesApprove))
+	moderation.GET("/responses", func(c *gin.Context) {
+		var filter responses.ResponseFilter
+		err := c.ShouldBindQuery(&filter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		queue, err := responses.GetModerationQueue(&filter, environment.Keys, environment.DB)
+		if err != nil {
+			if errors.Is(err, responses.ErrResponseQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"groups":      queue.Groups,
+			"next_cursor": queue.NextCursor,
+		})
+	})
+	moderation.POST("/responses/decisions", func(c *gin.Context) {
+		var request responses.DecisionRequest
+		err := c.ShouldBindJSON(&request)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		status, err := request.DecisionStatus()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		decisions, err := responses.DecideResponses(request.ResponseIDs, status, request.Note, c.GetInt64("user_id"), environment.DB)
+		if err != nil {
+			if errors.Is(err, responses.ErrDecision) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			if errors.Is(err, responses.ErrResponseNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"decisions": decisions})
 	})
 
 	provider := environment.Router.Group("/provider")
-	provider.PUT("/:id/approve/:approval", adminAuthRequired(environment), func(c *gin.Context) {
+	provider.PUT("/:id/approve/:approval", requirePermission(environment, users.PermProvidersApprove), func(c *gin.Context) {
 		approval, err := strconv.ParseBool(c.Param("approval"))
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -601,8 +1415,25 @@ func setup() *env.Env {
 			})
 			return
 		}
-		err = users.ApproveProvider(id, approval, environment.DB)
+		// a reason is only required when rejecting or suspending, so the body is optional
+		var statusReq users.ProviderStatusReq
+		if c.Request.ContentLength > 0 {
+			err = c.ShouldBindJSON(&statusReq)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+		}
+		_, err = users.ApproveProvider(id, approval, statusReq.Reason, c.GetInt64("user_id"), environment)
 		if err != nil {
+			if errors.Is(err, users.ErrProviderStatus) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
@@ -610,6 +1441,58 @@ func setup() *env.Env {
 		}
 		c.Status(http.StatusOK)
 	})
+	provider.PUT("/:id/status", requirePermission(environment, users.PermProvidersApprove), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		var statusReq users.ProviderStatusReq
+		err = c.ShouldBindJSON(&statusReq)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		change, err := users.SetProviderStatus(id, statusReq.Status, statusReq.Reason, c.GetInt64("user_id"), environment)
+		if err != nil {
+			if errors.Is(err, users.ErrProviderStatus) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"status": change,
+		})
+	})
+	provider.GET("/:id/status/history", requirePermission(environment, users.PermUsersRead), func(c *gin.Context) {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		history, err := users.GetProviderStatusHistory(id, environment.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"history": history,
+		})
+	})
 
 	return environment
 }
@@ -617,9 +1500,108 @@ func setup() *env.Env {
 func main() {
 	env := setup()
 	defer env.DB.Close()
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt" {
+		reencrypt(env)
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill-addresses" {
+		updated, err := users.BackfillAddresses(env.DB)
+		if err != nil {
+			log.Fatal("Failed to backfill addresses: " + err.Error())
+		}
+		log.Printf("Backfilled addresses of %d users", updated)
+		return
+	}
 	env.Router.Run()
 }
 
+// reencrypt reseals stored answers after ENCRYPTION_KEYS changes. Run it with the new key
+// listed first and the old keys after it; once it finishes the old keys can be removed.
+func reencrypt(environment *env.Env) {
+	result, err := responses.Reencrypt(environment.Keys, environment.DB)
+	if err != nil {
+		log.Fatal("Failed to reencrypt responses: " + err.Error())
+	}
+	tallyResponses, err := tally.Reencrypt(environment.Keys, environment.DB)
+	if err != nil {
+		log.Fatal("Failed to reencrypt tally responses: " + err.Error())
+	}
+	if result.Responses > 0 {
+		// answers to elements that are no longer sensitive can be indexed now they are decrypted
+		allForms, err := forms.GetForms(environment.DB)
+		if err != nil {
+			log.Fatal("Failed to get forms: " + err.Error())
+		}
+		for _, form := range allForms {
+			err = responses.ReindexForm(form.ID, environment.DB)
+			if err != nil {
+				log.Fatal("Failed to reindex form: " + err.Error())
+			}
+		}
+	}
+	log.Printf("Reencrypted %d responses, %d revisions, %d drafts and %d tally responses", result.Responses, result.Revisions, result.Drafts, tallyResponses)
+}
+
+// listResponses serves a page of responses filtered by the query string. scope, when set,
+// applies the filters fixed by the route, overriding the query.
+func listResponses(environment *env.Env, scope func(c *gin.Context, filter *responses.ResponseFilter) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var filter responses.ResponseFilter
+		err := c.ShouldBindQuery(&filter)
+		if err == nil && scope != nil {
+			err = scope(c, &filter)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		keys := environment.Keys
+		if filter.Audience != "" {
+			// public listings never open sealed answers
+			keys = nil
+		}
+		page, err := responses.GetResponses(&filter, keys, environment.DB)
+		if err != nil {
+			if errors.Is(err, responses.ErrResponseQuery) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error": err.Error(),
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"responses":   page.Responses,
+			"next_cursor": page.NextCursor,
+		})
+	}
+}
+
+// viewerAudience works out which element visibilities the caller of a public endpoint can see.
+// Signing in is optional, so a missing or invalid session is treated as the public.
+func viewerAudience(c *gin.Context, environment *env.Env) string {
+	token := c.Request.Header.Get("Authorization")
+	if token == "" {
+		return forms.VisibilityPublic
+	}
+	user, err := users.GetUserBySession(token, environment)
+	if err != nil {
+		return forms.VisibilityPublic
+	}
+	if user.HasPermission(users.PermResponsesRead) {
+		return forms.VisibilityAdmin
+	}
+	if user.ProviderStatus == string(users.ProviderApproved) {
+		return forms.VisibilityProviders
+	}
+	return forms.VisibilityPublic
+}
+
 func authRequired(environment *env.Env) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Request.Header.Get("Authorization")
@@ -644,7 +1626,8 @@ func authRequired(environment *env.Env) gin.HandlerFunc {
 	}
 }
 
-func adminAuthRequired(environment *env.Env) gin.HandlerFunc {
+// requirePermission authenticates the user and checks that one of their active roles grants the permission
+func requirePermission(environment *env.Env, permission string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.Request.Header.Get("Authorization")
 		if token == "" {
@@ -662,17 +1645,66 @@ func adminAuthRequired(environment *env.Env) gin.HandlerFunc {
 			c.Abort()
 			return
 		}
-		for _, role := range user.ActiveRoles {
-			if role == "admin" {
-				c.Set("user_id", user.ID)
-				c.Set("stytch_user_id", user.StytchUserID)
-				c.Next()
-				return
-			}
+		if !user.HasPermission(permission) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "User does not have permission " + permission,
+			})
+			c.Abort()
+			return
 		}
+		c.Set("user_id", user.ID)
+		c.Set("stytch_user_id", user.StytchUserID)
+		c.Next()
+	}
+}
+
+// checkRoleGrant checks that the user making the request can change who holds a role, and
+// responds with the error if they can't
+func checkRoleGrant(c *gin.Context, roleID int64, environment *env.Env) error {
+	user, err := users.GetUserBySession(c.Request.Header.Get("Authorization"), environment)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "User is not an admin",
+			"error": err.Error(),
 		})
-		c.Abort()
+		return err
 	}
+	err = users.CheckRoleGrant(user, roleID, environment.DB)
+	if errors.Is(err, users.ErrRoleNotGrantable) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return err
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return err
+	}
+	return nil
+}
+
+// respondReviewError responds to errors from commenting on or resubmitting a response
+func respondReviewError(c *gin.Context, err error) {
+	if errors.Is(err, responses.ErrResponseNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, responses.ErrNotResponseOwner) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, responses.ErrReview) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": err.Error(),
+	})
 }
@@ -20,6 +20,12 @@ func TestRoutes(t *testing.T) {
 	env := setup()
 	defer env.DB.Close()
 
+	testToken, testSessionToken, err := users.TestTokens(env)
+	if err != nil {
+		t.Error("Failed to get test tokens: " + err.Error())
+		return
+	}
+
 	body, err := json.Marshal(users.UserReq{
 		Email:       users.TestUser,
 		RedirectURL: users.TestRedirectURL,
@@ -34,7 +40,7 @@ func TestRoutes(t *testing.T) {
 	}
 
 	authTokenBody, err := json.Marshal(users.Auth{
-		Token: users.TestToken,
+		Token: testToken,
 	})
 	if err != nil {
 		t.Error("Failed to marshal auth token body: " + err.Error())
@@ -63,7 +69,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create request: " + err.Error())
 	}
-	getFormsReq.Header.Set("Authorization", users.TestSessionToken)
+	getFormsReq.Header.Set("Authorization", testSessionToken)
 
 	getFormsBodyTest := func(t *testing.T, bdy []byte) bool {
 		t.Log("Body: " + string(bdy))
@@ -86,7 +92,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create get form request: " + err.Error())
 	}
-	getFormReq.Header.Set("Authorization", users.TestSessionToken)
+	getFormReq.Header.Set("Authorization", testSessionToken)
 
 	getFormBodyTest := func(t *testing.T, bdy []byte) bool {
 		type formResp struct {
@@ -121,7 +127,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create update user request: " + err.Error())
 	}
-	updateUserReq.Header.Set("Authorization", users.TestSessionToken)
+	updateUserReq.Header.Set("Authorization", testSessionToken)
 
 	updateUserTest := func(t *testing.T, bdy []byte) bool {
 		type updateUserResp struct {
@@ -171,13 +177,13 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create update incorrect user request: " + err.Error())
 	}
-	updateIncorrectUserReq.Header.Set("Authorization", users.TestSessionToken)
+	updateIncorrectUserReq.Header.Set("Authorization", testSessionToken)
 
 	getUserReq, err := http.NewRequest("GET", "/user", nil)
 	if err != nil {
 		t.Error("Failed to create get user request: " + err.Error())
 	}
-	getUserReq.Header.Set("Authorization", users.TestSessionToken)
+	getUserReq.Header.Set("Authorization", testSessionToken)
 
 	newResponseBody, err := json.Marshal(responses.Response{
 		ElementID: 1,
@@ -190,7 +196,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create new response request: " + err.Error())
 	}
-	newResponseReq.Header.Set("Authorization", users.TestSessionToken)
+	newResponseReq.Header.Set("Authorization", testSessionToken)
 	type newResponseResp struct {
 		Response responses.Response `json:"response"`
 	}
@@ -229,7 +235,7 @@ func TestRoutes(t *testing.T) {
 		t.Error("Failed to create new response with options request: " + err.Error())
 		return
 	}
-	newResponseWithOptionsReq.Header.Set("Authorization", users.TestSessionToken)
+	newResponseWithOptionsReq.Header.Set("Authorization", testSessionToken)
 	newResponseWithOptionsTest := func(t *testing.T, bdy []byte) bool {
 		var resp newResponseResp
 		err := json.Unmarshal(bdy, &resp)
@@ -252,7 +258,7 @@ func TestRoutes(t *testing.T) {
 		return true
 	}
 
-	testUser, err := users.GetUserBySession(users.TestSessionToken, env)
+	testUser, err := users.GetUserBySession(testSessionToken, env)
 	if err != nil {
 		t.Error("Failed to get user ID: " + err.Error())
 		return
@@ -269,7 +275,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create get response request: " + err.Error())
 	}
-	getResponseReq.Header.Set("Authorization", users.TestSessionToken)
+	getResponseReq.Header.Set("Authorization", testSessionToken)
 	getResponseTest := func(t *testing.T, bdy []byte) bool {
 		type getResponseResp struct {
 			Response responses.Response `json:"response"`
@@ -308,13 +314,13 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create get response request: " + err.Error())
 	}
-	getResponseWithIncorrectUserReq.Header.Set("Authorization", users.TestSessionToken)
+	getResponseWithIncorrectUserReq.Header.Set("Authorization", testSessionToken)
 
 	getResponsesReq, err := http.NewRequest("GET", "/responses", nil)
 	if err != nil {
 		t.Error("Failed to create get responses request: " + err.Error())
 	}
-	getResponsesReq.Header.Set("Authorization", users.TestSessionToken)
+	getResponsesReq.Header.Set("Authorization", testSessionToken)
 	type getResponsesResp struct {
 		Responses []responses.Response `json:"responses"`
 	}
@@ -343,7 +349,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create get form responses request: " + err.Error())
 	}
-	getFormResponsesReq.Header.Set("Authorization", users.TestSessionToken)
+	getFormResponsesReq.Header.Set("Authorization", testSessionToken)
 	getFormResponsesTest := func(t *testing.T, bdy []byte) bool {
 		type getFormResponsesResp struct {
 			Responses []responses.FormResponse `json:"form_responses"`
@@ -365,7 +371,7 @@ func TestRoutes(t *testing.T) {
 	if err != nil {
 		t.Error("Failed to create get form responses request: " + err.Error())
 	}
-	getResponsesByFormReq.Header.Set("Authorization", users.TestSessionToken)
+	getResponsesByFormReq.Header.Set("Authorization", testSessionToken)
 	getResponsesByFormTest := func(t *testing.T, bdy []byte) bool {
 		var resp getResponsesResp
 		err := json.Unmarshal(bdy, &resp)
@@ -0,0 +1,22 @@
+-- Named permissions granted to roles. Permission names are defined in users/permissions.go.
+CREATE TABLE role_permissions (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  roleID BIGINT NOT NULL,
+  permission VARCHAR(64) NOT NULL,
+  UNIQUE KEY role_permission (roleID, permission)
+);
+
+-- admins keep every permission they had with the old "admin" role check
+INSERT INTO role_permissions (roleID, permission)
+SELECT r.id, p.permission
+FROM roles r, (
+  SELECT 'users:read' AS permission
+  UNION SELECT 'users:write'
+  UNION SELECT 'roles:write'
+  UNION SELECT 'forms:read'
+  UNION SELECT 'forms:write'
+  UNION SELECT 'responses:read'
+  UNION SELECT 'responses:approve'
+  UNION SELECT 'providers:approve'
+) p
+WHERE r.name = 'admin';
@@ -0,0 +1,15 @@
+-- Provider lifecycle status, replacing the approvedProvider flag. approvedProvider
+-- is still written for compatibility but is no longer read by the API.
+ALTER TABLE users ADD COLUMN providerStatus VARCHAR(32) NULL;
+UPDATE users SET providerStatus = 'approved' WHERE approvedProvider = true;
+
+CREATE TABLE provider_status_history (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  userID BIGINT NOT NULL,
+  status VARCHAR(32) NOT NULL,
+  previousStatus VARCHAR(32) NULL,
+  reason TEXT NULL,
+  actorID BIGINT NULL,
+  createdAt DATETIME NOT NULL,
+  KEY userID (userID)
+);
@@ -0,0 +1,10 @@
+-- Structured provider addresses. latitude and longitude are set from the zip code
+-- when a user is updated and are used to find nearby providers.
+ALTER TABLE users
+  ADD COLUMN street VARCHAR(255) NULL,
+  ADD COLUMN city VARCHAR(255) NULL,
+  ADD COLUMN state VARCHAR(2) NULL,
+  ADD COLUMN zip VARCHAR(10) NULL,
+  ADD COLUMN latitude DOUBLE NULL,
+  ADD COLUMN longitude DOUBLE NULL,
+  ADD KEY location (latitude, longitude);
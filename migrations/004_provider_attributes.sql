@@ -0,0 +1,22 @@
+-- Approved answers to searchable form elements, one row per value, so the provider
+-- directory can filter on them. Rebuilt from responses by the API; never edited directly.
+CREATE TABLE provider_attributes (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  userID BIGINT NOT NULL,
+  elementID BIGINT NOT NULL,
+  responseID BIGINT NOT NULL,
+  optionID BIGINT NULL,
+  value TEXT NOT NULL,
+  KEY userID (userID),
+  KEY elementValue (elementID, value(191))
+);
+
+-- backfill from responses that are already approved
+INSERT INTO provider_attributes (userID, elementID, responseID, value)
+SELECT r.userID, r.elementID, r.id, r.value FROM responses r, elements e
+WHERE r.elementID = e.id AND r.value IS NOT NULL AND r.value <> '' AND r.approved = true AND e.search = true
+  AND r.id = (SELECT max(r2.id) FROM responses r2 WHERE r2.userID = r.userID AND r2.elementID = r.elementID AND r2.approved = true);
+INSERT INTO provider_attributes (userID, elementID, responseID, optionID, value)
+SELECT r.userID, r.elementID, r.id, o.id, o.name FROM responses r, elements e, response_options ro, options o
+WHERE r.elementID = e.id AND ro.responseID = r.id AND o.id = ro.optionID AND r.approved = true AND e.search = true
+  AND r.id = (SELECT max(r2.id) FROM responses r2 WHERE r2.userID = r.userID AND r2.elementID = r.elementID AND r2.approved = true);
@@ -0,0 +1,9 @@
+-- Element types are now checked against a fixed list (see forms/types.go). Map the
+-- free-form types used so far onto it; anything left over must be fixed by hand
+-- before its form can be saved or answered:
+--   SELECT id, formID, label, type FROM elements WHERE type NOT IN ('short_text', 'long_text',
+--     'email', 'phone', 'url', 'number', 'date', 'boolean', 'single_select', 'multi_select');
+UPDATE elements SET type = 'short_text' WHERE type IN ('text', 'string', 'input');
+UPDATE elements SET type = 'long_text' WHERE type IN ('textarea', 'paragraph');
+UPDATE elements SET type = 'single_select' WHERE type IN ('select', 'dropdown', 'radio');
+UPDATE elements SET type = 'multi_select' WHERE type IN ('checkbox', 'checkboxes', 'multiselect');
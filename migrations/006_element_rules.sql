@@ -0,0 +1,2 @@
+-- Optional validation rules for an element's answers, as JSON (see forms.Rules)
+ALTER TABLE elements ADD COLUMN rules JSON NULL;
@@ -0,0 +1,26 @@
+-- Form versions. Elements and options belong to a single version; editing a published
+-- version copies it into a new draft. originID links copies of an element across versions.
+CREATE TABLE form_versions (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  formID BIGINT NOT NULL,
+  version INT NOT NULL,
+  publishedAt DATETIME NULL,
+  createdAt DATETIME NOT NULL,
+  UNIQUE KEY formVersion (formID, version)
+);
+ALTER TABLE forms ADD COLUMN currentVersionID BIGINT NULL;
+ALTER TABLE elements
+  ADD COLUMN versionID BIGINT NULL,
+  ADD COLUMN originID BIGINT NULL,
+  ADD KEY versionID (versionID),
+  ADD KEY originID (originID);
+ALTER TABLE responses ADD COLUMN formVersion INT NULL;
+
+-- every existing form becomes version 1, published
+INSERT INTO form_versions (formID, version, publishedAt, createdAt) SELECT id, 1, now(), now() FROM forms;
+UPDATE forms f JOIN form_versions v ON v.formID = f.id SET f.currentVersionID = v.id;
+UPDATE elements e JOIN forms f ON f.id = e.formID SET e.versionID = f.currentVersionID, e.originID = e.id;
+UPDATE responses SET formVersion = 1;
+
+-- attributes are indexed by the element's origin so they carry across versions
+UPDATE provider_attributes a JOIN elements e ON e.id = a.elementID SET a.elementID = e.originID;
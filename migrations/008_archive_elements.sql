@@ -0,0 +1,8 @@
+-- Elements and options removed from a form after they were answered are archived rather
+-- than deleted. Options get an originID like elements so answers can be matched across versions.
+ALTER TABLE elements ADD COLUMN archived BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE options
+  ADD COLUMN archived BOOLEAN NOT NULL DEFAULT false,
+  ADD COLUMN originID BIGINT NULL,
+  ADD KEY originID (originID);
+UPDATE options SET originID = id;
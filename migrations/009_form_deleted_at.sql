@@ -0,0 +1,2 @@
+-- Forms that have responses are marked deleted instead of being removed
+ALTER TABLE forms ADD COLUMN deletedAt DATETIME NULL;
@@ -0,0 +1,2 @@
+-- Conditions on earlier answers that decide whether an element is shown, as JSON (see forms.ConditionGroup)
+ALTER TABLE elements ADD COLUMN showIf JSON NULL;
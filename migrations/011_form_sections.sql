@@ -0,0 +1,24 @@
+-- Sections split a form into pages. Like elements they belong to one form version, and
+-- originID links copies of a section across versions. Elements without a section are in
+-- the form's implicit section.
+CREATE TABLE sections (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  formID BIGINT NOT NULL,
+  versionID BIGINT NOT NULL,
+  originID BIGINT NULL,
+  title VARCHAR(255) NOT NULL,
+  description TEXT NOT NULL,
+  position INT NOT NULL,
+  KEY versionID (versionID)
+);
+ALTER TABLE elements ADD COLUMN sectionID BIGINT NULL;
+
+-- The sections each user has saved, by section origin (0 for the implicit section)
+CREATE TABLE section_progress (
+  userID BIGINT NOT NULL,
+  formID BIGINT NOT NULL,
+  sectionID BIGINT NOT NULL,
+  formVersion INT NOT NULL,
+  savedAt DATETIME NOT NULL,
+  PRIMARY KEY (userID, formID, sectionID)
+);
@@ -0,0 +1,14 @@
+-- Unsubmitted answers to a form, one draft per user and form. answers is a JSON list keyed
+-- by element and option origin (see responses.SaveDraft).
+CREATE TABLE response_drafts (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  userID BIGINT NOT NULL,
+  formID BIGINT NOT NULL,
+  formVersion INT NOT NULL,
+  answers JSON NOT NULL,
+  createdAt DATETIME NOT NULL,
+  updatedAt DATETIME NOT NULL,
+  expiresAt DATETIME NOT NULL,
+  UNIQUE KEY userForm (userID, formID),
+  KEY expiresAt (expiresAt)
+);
@@ -0,0 +1,11 @@
+-- Earlier answers of responses that have been edited. optionIDs is a JSON list.
+CREATE TABLE response_revisions (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  responseID BIGINT NOT NULL,
+  value TEXT NULL,
+  optionIDs JSON NOT NULL,
+  approved BOOLEAN NOT NULL,
+  revisedBy BIGINT NOT NULL,
+  revisedAt DATETIME NOT NULL,
+  KEY responseID (responseID)
+);
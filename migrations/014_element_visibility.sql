@@ -0,0 +1,2 @@
+-- Who can see approved answers to an element outside of admin endpoints (see forms.VisibilityPublic)
+ALTER TABLE elements ADD COLUMN visibility VARCHAR(16) NOT NULL DEFAULT 'public';
@@ -0,0 +1,4 @@
+-- Answers to sensitive elements are stored sealed (see encryption.Keyring), which is longer
+-- than the answer itself
+ALTER TABLE elements ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE responses MODIFY value TEXT NULL;
@@ -0,0 +1,14 @@
+-- When a response was last approved or rejected; NULL while it waits for review
+ALTER TABLE responses ADD COLUMN reviewedAt DATETIME NULL;
+UPDATE responses SET reviewedAt = createdAt WHERE approved = true;
+
+-- Every approval or rejection of a response, with who made it
+CREATE TABLE response_decisions (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  responseID BIGINT NOT NULL,
+  approved BOOLEAN NOT NULL,
+  note TEXT NULL,
+  actorID BIGINT NULL,
+  createdAt DATETIME NOT NULL,
+  KEY responseID (responseID)
+);
@@ -0,0 +1,19 @@
+-- Where each response is in review: pending, approved, rejected or changes_requested
+ALTER TABLE responses ADD COLUMN reviewStatus VARCHAR(32) NOT NULL DEFAULT 'pending';
+UPDATE responses SET reviewStatus = 'approved' WHERE approved = true;
+UPDATE responses SET reviewStatus = 'rejected' WHERE approved = false AND reviewedAt IS NOT NULL;
+
+-- Decisions record the status they set
+ALTER TABLE response_decisions ADD COLUMN status VARCHAR(32) NULL;
+UPDATE response_decisions SET status = IF(approved, 'approved', 'rejected');
+
+-- The review thread between reviewers and the submitter of a response
+CREATE TABLE response_comments (
+  id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+  responseID BIGINT NOT NULL,
+  authorID BIGINT NULL,
+  body TEXT NOT NULL,
+  status VARCHAR(32) NULL,
+  createdAt DATETIME NOT NULL,
+  KEY responseID (responseID)
+);
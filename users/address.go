@@ -0,0 +1,107 @@
+package users
+
+import (
+	"api/geo"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// streetAddress matches the end of a free text address: the state and zip code, with the city
+// and street before them
+var streetAddress = regexp.MustCompile(`^(.*?)[,\s]+([A-Za-z]{2})\.?\s+(\d{5})(?:-\d{4})?\s*$`)
+
+type parsedAddress struct {
+	street string
+	city   string
+	state  string
+	zip    string
+}
+
+// parseAddress splits an address like "123 Main St, Denver, CO 80202" into its parts. Only
+// addresses ending in a state and zip code are understood.
+func parseAddress(address string) (parsedAddress, bool) {
+	match := streetAddress.FindStringSubmatch(strings.TrimSpace(address))
+	if match == nil {
+		return parsedAddress{}, false
+	}
+	parsed := parsedAddress{state: strings.ToUpper(match[2]), zip: match[3]}
+	parts := strings.Split(match[1], ",")
+	parsed.city = strings.TrimSpace(parts[len(parts)-1])
+	if len(parts) > 1 {
+		parsed.street = strings.TrimSpace(strings.Join(parts[:len(parts)-1], ","))
+	}
+	return parsed, true
+}
+
+// BackfillAddresses fills in the structured address of users saved before it existed, from
+// their free text address, and sets coordinates for zip codes that couldn't be located before.
+// Fields the user has filled in are kept. It returns how many users were updated and is safe
+// to run again.
+func BackfillAddresses(db *sql.DB) (int, error) {
+	type storedAddress struct {
+		id      int64
+		address string
+		zip     string
+	}
+	rows, err := db.Query("SELECT id, address, zip FROM users WHERE ((zip IS NULL OR zip = '') AND address IS NOT NULL AND address != '') OR (zip IS NOT NULL AND zip != '' AND latitude IS NULL)")
+	if err != nil {
+		return 0, errors.New("failed to select users to backfill: " + err.Error())
+	}
+	var stored []storedAddress
+	for rows.Next() {
+		var s storedAddress
+		var address, zip sql.NullString
+		err := rows.Scan(&s.id, &address, &zip)
+		if err != nil {
+			rows.Close()
+			return 0, errors.New("failed to scan user to backfill: " + err.Error())
+		}
+		s.address = address.String
+		s.zip = zip.String
+		stored = append(stored, s)
+	}
+	rows.Close()
+
+	updated := 0
+	for _, s := range stored {
+		parsed := parsedAddress{zip: s.zip}
+		if s.zip == "" {
+			var ok bool
+			parsed, ok = parseAddress(s.address)
+			if !ok {
+				continue
+			}
+		}
+		var latitude, longitude sql.NullFloat64
+		if point, ok := geo.LookupZip(parsed.zip); ok {
+			latitude = sql.NullFloat64{Float64: point.Lat, Valid: true}
+			longitude = sql.NullFloat64{Float64: point.Lng, Valid: true}
+		}
+		if s.zip != "" && !latitude.Valid {
+			continue
+		}
+		// users who saved a different zip code since they were read are left as they are
+		result, err := db.Exec(
+			"UPDATE users SET street = COALESCE(NULLIF(street, ''), ?), city = COALESCE(NULLIF(city, ''), ?), state = COALESCE(NULLIF(state, ''), ?), zip = COALESCE(NULLIF(zip, ''), ?), latitude = ?, longitude = ? WHERE id = ? AND COALESCE(zip, '') IN ('', ?)",
+			sql.NullString{String: parsed.street, Valid: parsed.street != ""},
+			sql.NullString{String: parsed.city, Valid: parsed.city != ""},
+			sql.NullString{String: parsed.state, Valid: parsed.state != ""},
+			parsed.zip,
+			latitude,
+			longitude,
+			s.id,
+			parsed.zip,
+		)
+		if err != nil {
+			return updated, errors.New("failed to backfill user address: " + err.Error())
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			return updated, errors.New("failed to backfill user address: " + err.Error())
+		}
+		updated += int(count)
+	}
+	return updated, nil
+}
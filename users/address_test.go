@@ -0,0 +1,23 @@
+package users
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    parsedAddress
+		ok      bool
+	}{
+		{"123 Main St, Denver, CO 80202", parsedAddress{street: "123 Main St", city: "Denver", state: "CO", zip: "80202"}, true},
+		{"Suite 4, 1 Pearl St, Boulder, co. 80302-1234", parsedAddress{street: "Suite 4, 1 Pearl St", city: "Boulder", state: "CO", zip: "80302"}, true},
+		{"Fort Collins CO 80521", parsedAddress{city: "Fort Collins", state: "CO", zip: "80521"}, true},
+		{"123 Main St, Denver", parsedAddress{}, false},
+		{"", parsedAddress{}, false},
+	}
+	for _, test := range tests {
+		got, ok := parseAddress(test.address)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseAddress(%q) = %+v, %v; want %+v, %v", test.address, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package users
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+// ProviderAttribute is a provider's approved answer to a searchable form element. Labels and
+// priorities come from the current version of the form.
+type ProviderAttribute struct {
+	ElementID int64    `json:"element_id"` // the element's origin_id
+	Label     string   `json:"label"`
+	Priority  int      `json:"priority"`
+	Values    []string `json:"values"`
+}
+
+// attachAttributes loads the attributes for a list of providers in one query. Attributes are
+// ordered by element priority, highest first, then by their position in the form.
+func attachAttributes(providers []*Provider, db *sql.DB) error {
+	if len(providers) == 0 {
+		return nil
+	}
+	byID := map[int64]*Provider{}
+	placeholders := make([]string, len(providers))
+	args := make([]interface{}, len(providers))
+	for i, provider := range providers {
+		provider.Attributes = []*ProviderAttribute{}
+		byID[provider.ID] = provider
+		placeholders[i] = "?"
+		args[i] = provider.ID
+	}
+	rows, err := db.Query(
+		"select a.userID, a.elementID, e.label, e.priority, a.value from provider_attributes a, elements e, forms f "+
+			"where a.elementID = e.originID and e.formID = f.id and e.versionID = f.currentVersionID and f.deletedAt is null and e.search = true "+
+			"and a.userID in ("+strings.Join(placeholders, ", ")+") "+
+			"order by a.userID, e.priority desc, f.id, e.position, a.id",
+		args...,
+	)
+	if err != nil {
+		return errors.New("error getting provider attributes. " + err.Error())
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var userID int64
+		var value string
+		var attribute ProviderAttribute
+		err := rows.Scan(&userID, &attribute.ElementID, &attribute.Label, &attribute.Priority, &value)
+		if err != nil {
+			return errors.New("error scanning provider attribute. " + err.Error())
+		}
+		provider := byID[userID]
+		last := len(provider.Attributes) - 1
+		if last >= 0 && provider.Attributes[last].ElementID == attribute.ElementID {
+			provider.Attributes[last].Values = append(provider.Attributes[last].Values, value)
+			continue
+		}
+		attribute.Values = []string{value}
+		provider.Attributes = append(provider.Attributes, &attribute)
+	}
+	return nil
+}
@@ -7,9 +7,11 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stytchauth/stytch-go/v3/stytch"
 )
 
+// sessions are renewed for 7 days every time they are used
+const sessionDurationMinutes = 10080
+
 type Auth struct {
 	Token string `json:"token"`
 }
@@ -38,19 +40,16 @@ func AuthenticateUser(c *gin.Context, e *env.Env) bool {
 
 // Authenticates a token
 func Authenticate(token string, e *env.Env) (sessionToken string, err error) {
-	resp, err := e.Stytch.MagicLinks.Authenticate(&stytch.MagicLinksAuthenticateParams{
-		Token:                  token,
-		SessionDurationMinutes: 10080,
-	})
+	session, err := e.Auth.AuthenticateToken(token, sessionDurationMinutes)
 	if err != nil {
 		return "", err
 	}
-	user, err := GetUserByStytchID(&resp.UserID, e)
+	user, err := GetUserByStytchID(&session.UserID, e)
 	if err != nil {
 		return "", err
 	}
 	if user == nil {
-		return "", errors.New("User not found. Stytch user ID " + resp.UserID)
+		return "", errors.New("User not found. Stytch user ID " + session.UserID)
 	}
-	return resp.SessionToken, nil
+	return session.SessionToken, nil
 }
@@ -0,0 +1,209 @@
+package users
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultDirectoryLimit = 20
+	maxDirectoryLimit     = 100
+)
+
+// ErrProviderQuery is wrapped by errors for invalid directory queries
+var ErrProviderQuery = errors.New("invalid provider query")
+
+// ProviderQuery filters, sorts and pages the provider directory
+type ProviderQuery struct {
+	Search    string `form:"q"`
+	Specialty string `form:"specialty"`
+	City      string `form:"city"`
+	Zip       string `form:"zip"`
+	Pronouns  string `form:"pronouns"`
+	Sort      string `form:"sort"`  // name or practice_name
+	Order     string `form:"order"` // asc or desc
+	Cursor    string `form:"cursor"`
+	Limit     int    `form:"limit"`
+	// Attributes filters on answers to searchable elements, keyed by the element's origin ID,
+	// e.g. attr[12]=Spanish. Bound from the query with QueryMap.
+	Attributes map[string]string `form:"-"`
+}
+
+// ProviderPage is one page of directory results
+type ProviderPage struct {
+	Providers  []*Provider `json:"providers"`
+	Total      int64       `json:"total"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// directoryCursor marks the last provider returned so the next page can continue after it
+type directoryCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// sort keys for the directory. Each is paired with the provider ID so paging is stable.
+var directorySorts = map[string]string{
+	"name":          "concat(coalesce(lastName, ''), ' ', coalesce(firstName, ''))",
+	"practice_name": "coalesce(practiceName, '')",
+}
+
+func encodeCursor(cursor directoryCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*directoryCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrProviderQuery)
+	}
+	var cursor directoryCursor
+	err = json.Unmarshal(data, &cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed cursor", ErrProviderQuery)
+	}
+	return &cursor, nil
+}
+
+// likePattern matches a value anywhere in a column, treating LIKE wildcards in the value literally
+func likePattern(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(value) + "%"
+}
+
+// where builds the filters shared by the count and page queries
+func (q *ProviderQuery) where() (string, []interface{}, error) {
+	conditions := []string{"providerStatus = 'approved'"}
+	var args []interface{}
+	if q.Search != "" {
+		conditions = append(conditions, "(concat(coalesce(firstName, ''), ' ', coalesce(lastName, '')) like ? or practiceName like ?)")
+		args = append(args, likePattern(q.Search), likePattern(q.Search))
+	}
+	if q.Specialty != "" {
+		conditions = append(conditions, "specialty like ?")
+		args = append(args, likePattern(q.Specialty))
+	}
+	if q.City != "" {
+		conditions = append(conditions, "city = ?")
+		args = append(args, q.City)
+	}
+	if q.Zip != "" {
+		conditions = append(conditions, "zip = ?")
+		args = append(args, q.Zip)
+	}
+	if q.Pronouns != "" {
+		conditions = append(conditions, "pronouns like ?")
+		args = append(args, likePattern(q.Pronouns))
+	}
+	elementIDs := make([]string, 0, len(q.Attributes))
+	for key := range q.Attributes {
+		elementIDs = append(elementIDs, key)
+	}
+	// map order is random, so sort to keep the query text stable
+	sort.Strings(elementIDs)
+	for _, key := range elementIDs {
+		elementID, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: attribute filters must be keyed by element id", ErrProviderQuery)
+		}
+		conditions = append(conditions, "exists (select 1 from provider_attributes a, elements e, forms f where a.elementID = e.originID and e.formID = f.id and e.versionID = f.currentVersionID and f.deletedAt is null and e.search = true and a.userID = users.id and a.elementID = ? and a.value = ?)")
+		args = append(args, elementID, q.Attributes[key])
+	}
+	return strings.Join(conditions, " and "), args, nil
+}
+
+// SearchProviders returns a page of approved providers matching the query
+func SearchProviders(q *ProviderQuery, db *sql.DB) (*ProviderPage, error) {
+	if q.Sort == "" {
+		q.Sort = "name"
+	}
+	sortKey, ok := directorySorts[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown sort %s", ErrProviderQuery, q.Sort)
+	}
+	if q.Order == "" {
+		q.Order = "asc"
+	}
+	if q.Order != "asc" && q.Order != "desc" {
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrProviderQuery)
+	}
+	if q.Limit <= 0 {
+		q.Limit = defaultDirectoryLimit
+	}
+	if q.Limit > maxDirectoryLimit {
+		q.Limit = maxDirectoryLimit
+	}
+
+	where, args, err := q.where()
+	if err != nil {
+		return nil, err
+	}
+	page := ProviderPage{Providers: []*Provider{}}
+	err = db.QueryRow("select count(*) from users where "+where, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, errors.New("error counting providers. " + err.Error())
+	}
+
+	if q.Cursor != "" {
+		cursor, err := decodeCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		if cursor.Sort != q.Sort || cursor.Order != q.Order {
+			return nil, fmt.Errorf("%w: cursor does not match sort", ErrProviderQuery)
+		}
+		comparison := ">"
+		if q.Order == "desc" {
+			comparison = "<"
+		}
+		where += fmt.Sprintf(" and (%[1]s %[2]s ? or (%[1]s = ? and id %[2]s ?))", sortKey, comparison)
+		args = append(args, cursor.Value, cursor.Value, cursor.ID)
+	}
+	selectProviders := fmt.Sprintf(
+		"select %[1]s, %[2]s from users where %[3]s order by %[2]s %[4]s, id %[4]s limit ?",
+		providerColumns, sortKey, where, q.Order,
+	)
+	args = append(args, q.Limit+1)
+	rows, err := db.Query(selectProviders, args...)
+	if err != nil {
+		return nil, errors.New("error searching providers. " + err.Error())
+	}
+	defer rows.Close()
+
+	var lastSortValue string
+	for rows.Next() {
+		var dbProvider sqlProvider
+		var sortValue string
+		err := rows.Scan(append(dbProvider.fields(), &sortValue)...)
+		if err != nil {
+			return nil, errors.New("error scanning provider. " + err.Error())
+		}
+		if len(page.Providers) == q.Limit {
+			// there is at least one more provider after this page
+			last := page.Providers[len(page.Providers)-1]
+			page.NextCursor = encodeCursor(directoryCursor{
+				Sort:  q.Sort,
+				Order: q.Order,
+				Value: lastSortValue,
+				ID:    last.ID,
+			})
+			break
+		}
+		page.Providers = append(page.Providers, dbProvider.ToProvider())
+		lastSortValue = sortValue
+	}
+	err = attachAttributes(page.Providers, db)
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
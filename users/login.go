@@ -9,7 +9,6 @@ import (
 	"api/env"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stytchauth/stytch-go/v3/stytch"
 )
 
 type UserReq struct {
@@ -32,22 +31,17 @@ type UserRole struct {
 }
 
 func Login(user UserReq, e *env.Env) (*int64, error) {
-	body := stytch.MagicLinksEmailLoginOrCreateParams{
-		Email:              user.Email,
-		LoginMagicLinkURL:  user.RedirectURL,
-		SignupMagicLinkURL: user.RedirectURL,
-	}
-	resp, err := e.Stytch.MagicLinks.Email.LoginOrCreate(&body)
+	stytchUserID, err := e.Auth.SendMagicLink(user.Email, user.RedirectURL)
 	if err != nil {
 		return nil, errors.New("Failed to create magic link: " + err.Error())
 	}
 
-	row := e.DB.QueryRow("SELECT id FROM users WHERE email = ? AND stytchUserID = ?", user.Email, resp.UserID)
+	row := e.DB.QueryRow("SELECT id FROM users WHERE email = ? AND stytchUserID = ?", user.Email, stytchUserID)
 	var userID int64
 	err = row.Scan(&userID)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			result, err := e.SqlExecute(fmt.Sprintf("INSERT INTO users (stytchUserID, email) VALUES ('%s', '%s')", resp.UserID, user.Email))
+			result, err := e.SqlExecute(fmt.Sprintf("INSERT INTO users (stytchUserID, email) VALUES ('%s', '%s')", stytchUserID, user.Email))
 			if err != nil {
 				return nil, errors.New("Failed to create user: " + err.Error())
 			}
@@ -77,6 +71,7 @@ func Login(user UserReq, e *env.Env) (*int64, error) {
 				return nil, errors.New("Failed to query role: " + err.Error())
 			}
 			fmt.Printf("Added user %s to role %s\n", user.Email, name)
+			e.Sessions.InvalidateUser(userID)
 			// TODO: send notification to slack or email
 		}
 	}
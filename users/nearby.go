@@ -0,0 +1,64 @@
+package users
+
+import (
+	"api/geo"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	defaultNearbyRadius = 25.0
+	maxNearbyRadius     = 500.0
+)
+
+// GetProvidersNear returns approved providers within radius miles of a zip code, closest first.
+// A radius of 0 uses the default.
+func GetProvidersNear(zip string, radius float64, db *sql.DB) ([]*Provider, error) {
+	if radius == 0 {
+		radius = defaultNearbyRadius
+	}
+	if radius < 0 || radius > maxNearbyRadius {
+		return nil, fmt.Errorf("%w: radius must be between 0 and %g miles", ErrProviderQuery, maxNearbyRadius)
+	}
+	center, ok := geo.LookupZip(zip)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown zip code %s", ErrProviderQuery, zip)
+	}
+
+	// the bounding box narrows the query so only providers near the edge need the exact distance check
+	min, max := geo.BoundingBox(center, radius)
+	rows, err := db.Query(
+		"select "+providerColumns+" from users where providerStatus = 'approved' and latitude between ? and ? and longitude between ? and ?",
+		min.Lat, max.Lat, min.Lng, max.Lng,
+	)
+	if err != nil {
+		return nil, errors.New("error getting nearby providers. " + err.Error())
+	}
+	defer rows.Close()
+
+	providers := []*Provider{}
+	for rows.Next() {
+		var dbProvider sqlProvider
+		err := rows.Scan(dbProvider.fields()...)
+		if err != nil {
+			return nil, errors.New("error scanning provider. " + err.Error())
+		}
+		provider := dbProvider.ToProvider()
+		distance := geo.DistanceMiles(center, geo.Point{Lat: *provider.Latitude, Lng: *provider.Longitude})
+		if distance > radius {
+			continue
+		}
+		provider.Distance = &distance
+		providers = append(providers, provider)
+	}
+	sort.SliceStable(providers, func(i, j int) bool {
+		return *providers[i].Distance < *providers[j].Distance
+	})
+	err = attachAttributes(providers, db)
+	if err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
@@ -0,0 +1,144 @@
+package users
+
+import (
+	"api/env"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Permissions granted to roles through the role_permissions table
+const (
+	PermUsersRead        = "users:read"
+	PermUsersWrite       = "users:write"
+	PermRolesWrite       = "roles:write"
+	PermFormsRead        = "forms:read"
+	PermFormsWrite       = "forms:write"
+	PermResponsesRead    = "responses:read"
+	PermResponsesApprove = "responses:approve"
+	PermProvidersApprove = "providers:approve"
+)
+
+// Permissions lists every permission that can be granted to a role
+var Permissions = []string{
+	PermUsersRead,
+	PermUsersWrite,
+	PermRolesWrite,
+	PermFormsRead,
+	PermFormsWrite,
+	PermResponsesRead,
+	PermResponsesApprove,
+	PermProvidersApprove,
+}
+
+func ValidPermission(permission string) bool {
+	for _, p := range Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *User) HasPermission(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrRoleNotGrantable is returned when a user changes who holds a role that grants permissions
+// they don't have
+var ErrRoleNotGrantable = errors.New("role grants permissions you don't have")
+
+// CheckRoleGrant checks that user can add people to a role or remove them from it. Users with
+// roles:write can change any role; anyone else only roles whose permissions they all hold, so
+// users:write can't be used to give out more access than it has.
+func CheckRoleGrant(user *User, roleID int64, db *sql.DB) error {
+	if user.HasPermission(PermRolesWrite) {
+		return nil
+	}
+	permissions, err := GetRolePermissions(roleID, db)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !user.HasPermission(permission) {
+			return fmt.Errorf("%w: %s", ErrRoleNotGrantable, permission)
+		}
+	}
+	return nil
+}
+
+// getUserPermissions returns the permissions granted to a user's active roles
+func getUserPermissions(userID int64, db *sql.DB) ([]string, error) {
+	rows, err := db.Query("select distinct rp.permission from role_permissions rp, user_roles ur where rp.roleID = ur.roleID and ur.active = true and ur.userID = ?", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
+
+func GetRolePermissions(roleID int64, db *sql.DB) ([]string, error) {
+	rows, err := db.Query("select permission from role_permissions where roleID = ?", roleID)
+	if err != nil {
+		return nil, errors.New("error getting role permissions. " + err.Error())
+	}
+	defer rows.Close()
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, errors.New("error scanning role permission. " + err.Error())
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
+
+func GrantPermission(roleID int64, permission string, e *env.Env) error {
+	if !ValidPermission(permission) {
+		return fmt.Errorf("unknown permission %s", permission)
+	}
+	var selectedRole int64
+	err := e.DB.QueryRow("select id from roles where id = ?", roleID).Scan(&selectedRole)
+	if err != nil {
+		return errors.New("error getting role. " + err.Error())
+	}
+	var existingID int64
+	err = e.DB.QueryRow("select id from role_permissions where roleID = ? and permission = ?", roleID, permission).Scan(&existingID)
+	if err == nil {
+		return nil
+	}
+	if err != sql.ErrNoRows {
+		return errors.New("error getting role permission. " + err.Error())
+	}
+	_, err = e.DB.Exec("insert into role_permissions (roleID, permission) values (?, ?)", roleID, permission)
+	if err != nil {
+		return errors.New("error granting permission. " + err.Error())
+	}
+	e.Sessions.InvalidateAll()
+	return nil
+}
+
+func RevokePermission(roleID int64, permission string, e *env.Env) error {
+	_, err := e.DB.Exec("delete from role_permissions where roleID = ? and permission = ?", roleID, permission)
+	if err != nil {
+		return errors.New("error revoking permission. " + err.Error())
+	}
+	e.Sessions.InvalidateAll()
+	return nil
+}
@@ -3,18 +3,30 @@ package users
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 )
 
+// ErrProviderNotFound is returned for providers that don't exist or aren't approved
+var ErrProviderNotFound = errors.New("provider not found")
+
 type Provider struct {
-	ID           int64  `json:"id"`
-	Email        string `json:"email"`
-	FirstName    string `json:"first_name"`
-	LastName     string `json:"last_name"`
-	Pronouns     string `json:"pronouns"`
-	PracticeName string `json:"practice_name"`
-	Address      string `json:"address"`
-	Specialty    string `json:"specialty"`
-	Phone        string `json:"phone"`
+	ID           int64                `json:"id"`
+	Email        string               `json:"email"`
+	FirstName    string               `json:"first_name"`
+	LastName     string               `json:"last_name"`
+	Pronouns     string               `json:"pronouns"`
+	PracticeName string               `json:"practice_name"`
+	Address      string               `json:"address"`
+	Specialty    string               `json:"specialty"`
+	Phone        string               `json:"phone"`
+	Street       string               `json:"street"`
+	City         string               `json:"city"`
+	State        string               `json:"state"`
+	Zip          string               `json:"zip"`
+	Latitude     *float64             `json:"latitude"`
+	Longitude    *float64             `json:"longitude"`
+	Distance     *float64             `json:"distance,omitempty"` // miles, when searching by location
+	Attributes   []*ProviderAttribute `json:"attributes"`
 }
 
 type sqlProvider struct {
@@ -27,6 +39,35 @@ type sqlProvider struct {
 	Address      sql.NullString
 	Specialty    sql.NullString
 	Phone        sql.NullString
+	Street       sql.NullString
+	City         sql.NullString
+	State        sql.NullString
+	Zip          sql.NullString
+	Latitude     sql.NullFloat64
+	Longitude    sql.NullFloat64
+}
+
+// providerColumns are the users columns scanned by sqlProvider.fields
+const providerColumns = "id, email, firstName, lastName, pronouns, practiceName, address, specialty, phone, street, city, state, zip, latitude, longitude"
+
+func (p *sqlProvider) fields() []interface{} {
+	return []interface{}{
+		&p.ID,
+		&p.Email,
+		&p.FirstName,
+		&p.LastName,
+		&p.Pronouns,
+		&p.PracticeName,
+		&p.Address,
+		&p.Specialty,
+		&p.Phone,
+		&p.Street,
+		&p.City,
+		&p.State,
+		&p.Zip,
+		&p.Latitude,
+		&p.Longitude,
+	}
 }
 
 func (p *sqlProvider) ToProvider() *Provider {
@@ -40,19 +81,19 @@ func (p *sqlProvider) ToProvider() *Provider {
 	provider.Address = p.Address.String
 	provider.Specialty = p.Specialty.String
 	provider.Phone = p.Phone.String
-	return &provider
-}
-
-func ApproveProvider(userID int64, approved bool, db *sql.DB) error {
-	_, err := db.Exec("update users set approvedProvider = ? where id = ?", approved, userID)
-	if err != nil {
-		return errors.New("error updating user. " + err.Error())
+	provider.Street = p.Street.String
+	provider.City = p.City.String
+	provider.State = p.State.String
+	provider.Zip = p.Zip.String
+	if p.Latitude.Valid && p.Longitude.Valid {
+		provider.Latitude = &p.Latitude.Float64
+		provider.Longitude = &p.Longitude.Float64
 	}
-	return nil
+	return &provider
 }
 
 func GetApprovedProviders(db *sql.DB) ([]*Provider, error) {
-	rows, err := db.Query("select id, email, firstName, lastName, pronouns, practiceName, address, specialty, phone from users where approvedProvider = true")
+	rows, err := db.Query("select " + providerColumns + " from users where providerStatus = 'approved'")
 	if err != nil {
 		return nil, errors.New("error getting approved providers. " + err.Error())
 	}
@@ -61,42 +102,34 @@ func GetApprovedProviders(db *sql.DB) ([]*Provider, error) {
 	var providers []*Provider
 	for rows.Next() {
 		var dbProvider sqlProvider
-		err := rows.Scan(
-			&dbProvider.ID,
-			&dbProvider.Email,
-			&dbProvider.FirstName,
-			&dbProvider.LastName,
-			&dbProvider.Pronouns,
-			&dbProvider.PracticeName,
-			&dbProvider.Address,
-			&dbProvider.Specialty,
-			&dbProvider.Phone,
-		)
+		err := rows.Scan(dbProvider.fields()...)
 		if err != nil {
 			return nil, err
 		}
 		provider := dbProvider.ToProvider()
 		providers = append(providers, provider)
 	}
+	err = attachAttributes(providers, db)
+	if err != nil {
+		return nil, err
+	}
 	return providers, nil
 }
 
 func GetApprovedProvider(id *int64, db *sql.DB) (*Provider, error) {
-	row := db.QueryRow("select email, firstName, lastName, pronouns, practiceName, address, specialty, phone from users where id = ? and approvedProvider = true", id)
+	row := db.QueryRow("select "+providerColumns+" from users where id = ? and providerStatus = 'approved'", id)
 	var dbProvider sqlProvider
-	err := row.Scan(
-		&dbProvider.Email,
-		&dbProvider.FirstName,
-		&dbProvider.LastName,
-		&dbProvider.Pronouns,
-		&dbProvider.PracticeName,
-		&dbProvider.Address,
-		&dbProvider.Specialty,
-		&dbProvider.Phone,
-	)
+	err := row.Scan(dbProvider.fields()...)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: provider %v", ErrProviderNotFound, *id)
+	}
 	if err != nil {
 		return nil, errors.New("error getting approved provider. " + err.Error())
 	}
-	dbProvider.ID = *id
-	return dbProvider.ToProvider(), nil
+	provider := dbProvider.ToProvider()
+	err = attachAttributes([]*Provider{provider}, db)
+	if err != nil {
+		return nil, err
+	}
+	return provider, nil
 }
@@ -3,19 +3,20 @@ package users_test
 import (
 	"api/env"
 	"api/users"
+	"errors"
 	"testing"
 )
 
 func TestProviderApproval(t *testing.T) {
 	e := env.TestSetup(t, true, "../.env")
-	selectProvider := "select id from users where approvedProvider = false"
+	selectProvider := "select id from users where providerStatus is null or providerStatus in ('applied', 'under_review')"
 	var providerID int64
 	err := e.DB.QueryRow(selectProvider).Scan(&providerID)
 	if err != nil {
 		t.Error("error getting provider ID. " + err.Error())
 		return
 	}
-	err = users.ApproveProvider(providerID, true, e.DB)
+	_, err = users.ApproveProvider(providerID, true, "", 0, e)
 	if err != nil {
 		t.Error("error approving provider. " + err.Error())
 		return
@@ -29,11 +30,105 @@ func TestProviderApproval(t *testing.T) {
 	if !user.ApprovedProvider {
 		t.Error("user is not approved")
 	}
+	// approving again leaves the provider as they are
+	change, err := users.ApproveProvider(providerID, true, "", 0, e)
+	if err != nil {
+		t.Error("error approving an approved provider. " + err.Error())
+		return
+	}
+	if change.ID != 0 || change.Status != users.ProviderApproved {
+		t.Error("expected approving an approved provider to record nothing; got", change)
+	}
 
-	// remove approval from provider
-	err = users.ApproveProvider(providerID, false, e.DB)
+	// removing approval suspends the provider, which needs a reason
+	_, err = users.ApproveProvider(providerID, false, "", 0, e)
+	if !errors.Is(err, users.ErrProviderStatus) {
+		t.Error("expected error suspending a provider without a reason")
+	}
+	_, err = users.ApproveProvider(providerID, false, "integration test", 0, e)
 	if err != nil {
 		t.Error("error removing approval from provider. " + err.Error())
 		return
 	}
+	history, err := users.GetProviderStatusHistory(providerID, e.DB)
+	if err != nil {
+		t.Error("error getting provider status history. " + err.Error())
+		return
+	}
+	if len(history) < 2 {
+		t.Error("expected at least 2 status changes; got", len(history))
+		return
+	}
+	last := history[len(history)-1]
+	if last.Status != users.ProviderSuspended || last.Reason != "integration test" {
+		t.Error("expected last status change to be a suspension with a reason; got", last.Status, last.Reason)
+	}
+}
+
+func TestProviderTransitions(t *testing.T) {
+	testCases := []struct {
+		from users.ProviderStatus
+		to   users.ProviderStatus
+		want bool
+	}{
+		{from: "", to: users.ProviderApplied, want: true},
+		{from: users.ProviderApplied, to: users.ProviderUnderReview, want: true},
+		{from: users.ProviderUnderReview, to: users.ProviderApproved, want: true},
+		{from: users.ProviderApproved, to: users.ProviderSuspended, want: true},
+		{from: users.ProviderSuspended, to: users.ProviderApproved, want: true},
+		{from: users.ProviderWithdrawn, to: users.ProviderApplied, want: true},
+		{from: users.ProviderApproved, to: users.ProviderRejected, want: false},
+		{from: users.ProviderRejected, to: users.ProviderApproved, want: false},
+		{from: users.ProviderWithdrawn, to: users.ProviderApproved, want: false},
+		{from: "", to: users.ProviderSuspended, want: false},
+	}
+	for _, tc := range testCases {
+		if got := users.CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("CanTransition(%q, %q) = %v; want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestSearchProviders(t *testing.T) {
+	e := env.TestSetup(t, true, "../.env")
+	page, err := users.SearchProviders(&users.ProviderQuery{Limit: 1}, e.DB)
+	if err != nil {
+		t.Error("error searching providers. " + err.Error())
+		return
+	}
+	if int64(len(page.Providers)) > page.Total {
+		t.Error("expected no more providers than the total")
+	}
+	if page.Total > 1 && page.NextCursor == "" {
+		t.Error("expected a cursor for the next page")
+	}
+	seen := map[int64]bool{}
+	for _, provider := range page.Providers {
+		seen[provider.ID] = true
+	}
+	for page.NextCursor != "" {
+		page, err = users.SearchProviders(&users.ProviderQuery{Limit: 1, Cursor: page.NextCursor}, e.DB)
+		if err != nil {
+			t.Error("error getting next page. " + err.Error())
+			return
+		}
+		for _, provider := range page.Providers {
+			if seen[provider.ID] {
+				t.Error("provider", provider.ID, "returned on more than one page")
+			}
+			seen[provider.ID] = true
+		}
+	}
+	if int64(len(seen)) != page.Total {
+		t.Error("expected to page through", page.Total, "providers; got", len(seen))
+	}
+
+	_, err = users.SearchProviders(&users.ProviderQuery{Sort: "email"}, e.DB)
+	if !errors.Is(err, users.ErrProviderQuery) {
+		t.Error("expected error sorting by an unknown field")
+	}
+	_, err = users.SearchProviders(&users.ProviderQuery{Attributes: map[string]string{"languages": "Spanish"}}, e.DB)
+	if !errors.Is(err, users.ErrProviderQuery) {
+		t.Error("expected error filtering on an attribute without an element id")
+	}
 }
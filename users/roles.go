@@ -0,0 +1,143 @@
+package users
+
+import (
+	"api/env"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// RoleRequest is a user's inactive membership in a role, waiting for an admin
+type RoleRequest struct {
+	UserRole
+	Email    string `json:"email"`
+	RoleName string `json:"role_name"`
+}
+
+func GetRoles(db *sql.DB) ([]*Role, error) {
+	rows, err := db.Query("select id, name, protected from roles")
+	if err != nil {
+		return nil, errors.New("error getting roles. " + err.Error())
+	}
+	defer rows.Close()
+	roles := []*Role{}
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(&role.ID, &role.Name, &role.Protected)
+		if err != nil {
+			return nil, errors.New("error scanning role. " + err.Error())
+		}
+		roles = append(roles, &role)
+	}
+	return roles, nil
+}
+
+func NewRole(role *Role, db *sql.DB) (*Role, error) {
+	if role.Name == "" {
+		return nil, errors.New("role name is required")
+	}
+	var existingID int64
+	err := db.QueryRow("select id from roles where name = ?", role.Name).Scan(&existingID)
+	if err == nil {
+		return nil, fmt.Errorf("role %s already exists", role.Name)
+	}
+	if err != sql.ErrNoRows {
+		return nil, errors.New("error checking for existing role. " + err.Error())
+	}
+	result, err := db.Exec("insert into roles (name, protected) values (?, ?)", role.Name, role.Protected)
+	if err != nil {
+		return nil, errors.New("error inserting role. " + err.Error())
+	}
+	role.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, errors.New("error getting inserted role id. " + err.Error())
+	}
+	return role, nil
+}
+
+// DeleteRole removes a role along with its permissions and every user's membership in it
+func DeleteRole(id int64, e *env.Env) error {
+	tx, err := e.DB.Begin()
+	if err != nil {
+		return errors.New("error starting transaction. " + err.Error())
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec("delete from user_roles where roleID = ?", id)
+	if err != nil {
+		return errors.New("error deleting user roles. " + err.Error())
+	}
+	_, err = tx.Exec("delete from role_permissions where roleID = ?", id)
+	if err != nil {
+		return errors.New("error deleting role permissions. " + err.Error())
+	}
+	_, err = tx.Exec("delete from roles where id = ?", id)
+	if err != nil {
+		return errors.New("error deleting role. " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("error committing role deletion. " + err.Error())
+	}
+	e.Sessions.InvalidateAll()
+	return nil
+}
+
+// GetRoleRequests returns inactive user roles, which are created when a user asks to join a protected role
+func GetRoleRequests(db *sql.DB) ([]*RoleRequest, error) {
+	selectRequests := "select ur.id, ur.userID, ur.roleID, ur.active, u.email, r.name from user_roles ur, users u, roles r where ur.userID = u.id and ur.roleID = r.id and ur.active = false"
+	rows, err := db.Query(selectRequests)
+	if err != nil {
+		return nil, errors.New("error getting role requests. " + err.Error())
+	}
+	defer rows.Close()
+	requests := []*RoleRequest{}
+	for rows.Next() {
+		var request RoleRequest
+		err := rows.Scan(&request.ID, &request.UserID, &request.RoleID, &request.Active, &request.Email, &request.RoleName)
+		if err != nil {
+			return nil, errors.New("error scanning role request. " + err.Error())
+		}
+		requests = append(requests, &request)
+	}
+	return requests, nil
+}
+
+// SetUserRoleActive activates or deactivates a user's role, adding the user to the role if needed
+func SetUserRoleActive(userID int64, roleID int64, active bool, e *env.Env) error {
+	_, err := Get(userID, e.DB)
+	if err != nil {
+		return errors.New("error getting user. " + err.Error())
+	}
+	var selectedRole int64
+	err = e.DB.QueryRow("select id from roles where id = ?", roleID).Scan(&selectedRole)
+	if err != nil {
+		return errors.New("error getting role. " + err.Error())
+	}
+	userRole := UserRole{
+		UserID: userID,
+		RoleID: roleID,
+		Active: active,
+	}
+	created, err := userRole.addUserToRole(e)
+	if err != nil {
+		return errors.New("error adding user to role. " + err.Error())
+	}
+	if !created {
+		_, err = e.DB.Exec("update user_roles set active = ? where userID = ? and roleID = ?", active, userID, roleID)
+		if err != nil {
+			return errors.New("error updating user role. " + err.Error())
+		}
+	}
+	e.Sessions.InvalidateUser(userID)
+	return nil
+}
+
+// RevokeUserRole removes a user from a role
+func RevokeUserRole(userID int64, roleID int64, e *env.Env) error {
+	_, err := e.DB.Exec("delete from user_roles where userID = ? and roleID = ?", userID, roleID)
+	if err != nil {
+		return errors.New("error deleting user role. " + err.Error())
+	}
+	e.Sessions.InvalidateUser(userID)
+	return nil
+}
@@ -0,0 +1,153 @@
+package users_test
+
+import (
+	"api/env"
+	"api/users"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRoleManagement(t *testing.T) {
+	e := env.TestSetup(t, true, "../.env")
+	role, err := users.NewRole(&users.Role{
+		Name:      fmt.Sprintf("test-role-%d", time.Now().UnixNano()),
+		Protected: true,
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating role. " + err.Error())
+		return
+	}
+	defer func() {
+		err := users.DeleteRole(role.ID, e)
+		if err != nil {
+			t.Error("error deleting role. " + err.Error())
+		}
+	}()
+
+	var userID int64
+	var stytchUserID string
+	err = e.DB.QueryRow("select id, stytchUserID from users where email = ?", users.TestUser).Scan(&userID, &stytchUserID)
+	if err != nil {
+		t.Error("error getting test user. " + err.Error())
+		return
+	}
+
+	// an inactive role shows up as a request
+	err = users.SetUserRoleActive(userID, role.ID, false, e)
+	if err != nil {
+		t.Error("error adding inactive role. " + err.Error())
+		return
+	}
+	requests, err := users.GetRoleRequests(e.DB)
+	if err != nil {
+		t.Error("error getting role requests. " + err.Error())
+		return
+	}
+	found := false
+	for _, request := range requests {
+		if request.UserID == userID && request.RoleID == role.ID {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected inactive role to be listed as a request")
+	}
+
+	// activating the role gives it to the user
+	err = users.SetUserRoleActive(userID, role.ID, true, e)
+	if err != nil {
+		t.Error("error activating role. " + err.Error())
+		return
+	}
+	user, err := users.GetUserByStytchID(&stytchUserID, e)
+	if err != nil {
+		t.Error("error getting user. " + err.Error())
+		return
+	}
+	if !hasRole(user, role.Name) {
+		t.Error("expected user to have role", role.Name)
+	}
+
+	err = users.RevokeUserRole(userID, role.ID, e)
+	if err != nil {
+		t.Error("error revoking role. " + err.Error())
+		return
+	}
+	user, err = users.GetUserByStytchID(&stytchUserID, e)
+	if err != nil {
+		t.Error("error getting user. " + err.Error())
+		return
+	}
+	if hasRole(user, role.Name) {
+		t.Error("expected role to be revoked")
+	}
+}
+
+func hasRole(user *users.User, name string) bool {
+	for _, role := range user.ActiveRoles {
+		if role == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRolePermissions(t *testing.T) {
+	e := env.TestSetup(t, true, "../.env")
+	role, err := users.NewRole(&users.Role{
+		Name: fmt.Sprintf("test-moderator-%d", time.Now().UnixNano()),
+	}, e.DB)
+	if err != nil {
+		t.Error("error creating role. " + err.Error())
+		return
+	}
+	defer users.DeleteRole(role.ID, e)
+
+	err = users.GrantPermission(role.ID, "forms:delete-everything", e)
+	if err == nil {
+		t.Error("expected error granting an unknown permission")
+	}
+	err = users.GrantPermission(role.ID, users.PermResponsesApprove, e)
+	if err != nil {
+		t.Error("error granting permission. " + err.Error())
+		return
+	}
+	permissions, err := users.GetRolePermissions(role.ID, e.DB)
+	if err != nil {
+		t.Error("error getting role permissions. " + err.Error())
+		return
+	}
+	if len(permissions) != 1 || permissions[0] != users.PermResponsesApprove {
+		t.Error("expected role to only have", users.PermResponsesApprove, "; got", permissions)
+	}
+
+	// only users holding the role's permissions, or roles:write, can give it out
+	err = users.CheckRoleGrant(&users.User{Permissions: []string{users.PermUsersWrite}}, role.ID, e.DB)
+	if !errors.Is(err, users.ErrRoleNotGrantable) {
+		t.Error("expected ErrRoleNotGrantable for a user without the role's permissions; got", err)
+	}
+	err = users.CheckRoleGrant(&users.User{Permissions: []string{users.PermUsersWrite, users.PermResponsesApprove}}, role.ID, e.DB)
+	if err != nil {
+		t.Error("expected a user with the role's permissions to grant it. " + err.Error())
+	}
+	err = users.CheckRoleGrant(&users.User{Permissions: []string{users.PermRolesWrite}}, role.ID, e.DB)
+	if err != nil {
+		t.Error("expected a user with roles:write to grant any role. " + err.Error())
+	}
+
+	err = users.RevokePermission(role.ID, users.PermResponsesApprove, e)
+	if err != nil {
+		t.Error("error revoking permission. " + err.Error())
+		return
+	}
+	permissions, err = users.GetRolePermissions(role.ID, e.DB)
+	if err != nil {
+		t.Error("error getting role permissions. " + err.Error())
+		return
+	}
+	if len(permissions) != 0 {
+		t.Error("expected role to have no permissions; got", permissions)
+	}
+}
@@ -0,0 +1,203 @@
+package users
+
+import (
+	"api/env"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type ProviderStatus string
+
+const (
+	ProviderApplied     ProviderStatus = "applied"
+	ProviderUnderReview ProviderStatus = "under_review"
+	ProviderApproved    ProviderStatus = "approved"
+	ProviderRejected    ProviderStatus = "rejected"
+	ProviderSuspended   ProviderStatus = "suspended"
+	ProviderWithdrawn   ProviderStatus = "withdrawn"
+)
+
+// ErrProviderStatus is wrapped by errors for status changes that are not allowed
+var ErrProviderStatus = errors.New("invalid provider status change")
+
+// providerTransitions lists the statuses a provider can move to from each status.
+// Users who have never been a provider have an empty status.
+var providerTransitions = map[ProviderStatus][]ProviderStatus{
+	"":                  {ProviderApplied, ProviderUnderReview, ProviderApproved, ProviderRejected},
+	ProviderApplied:     {ProviderUnderReview, ProviderApproved, ProviderRejected, ProviderWithdrawn},
+	ProviderUnderReview: {ProviderApproved, ProviderRejected, ProviderWithdrawn},
+	ProviderApproved:    {ProviderSuspended, ProviderWithdrawn},
+	ProviderRejected:    {ProviderApplied, ProviderUnderReview},
+	ProviderSuspended:   {ProviderApproved, ProviderUnderReview, ProviderWithdrawn},
+	ProviderWithdrawn:   {ProviderApplied},
+}
+
+// ProviderStatusChange is an entry in a provider's status history
+type ProviderStatusChange struct {
+	ID             int64          `json:"id"`
+	UserID         int64          `json:"user_id"`
+	Status         ProviderStatus `json:"status"`
+	PreviousStatus ProviderStatus `json:"previous_status"`
+	Reason         string         `json:"reason"`
+	ActorID        int64          `json:"actor_id"`
+	CreatedAt      time.Time      `json:"created_at"`
+}
+
+type ProviderStatusReq struct {
+	Status ProviderStatus `json:"status"`
+	Reason string         `json:"reason"`
+}
+
+// CanTransition reports whether a provider can move from one status to another
+func CanTransition(from ProviderStatus, to ProviderStatus) bool {
+	for _, status := range providerTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ReasonRequired reports whether moving to a status must include a reason
+func ReasonRequired(status ProviderStatus) bool {
+	return status == ProviderRejected || status == ProviderSuspended
+}
+
+func validateStatusChange(from ProviderStatus, to ProviderStatus, reason string) error {
+	if _, ok := providerTransitions[to]; !ok || to == "" {
+		return fmt.Errorf("%w: unknown status %s", ErrProviderStatus, to)
+	}
+	if !CanTransition(from, to) {
+		if from == "" {
+			from = "none"
+		}
+		return fmt.Errorf("%w: cannot move from %s to %s", ErrProviderStatus, from, to)
+	}
+	if ReasonRequired(to) && reason == "" {
+		return fmt.Errorf("%w: a reason is required for %s", ErrProviderStatus, to)
+	}
+	return nil
+}
+
+func getProviderStatus(userID int64, db *sql.DB) (ProviderStatus, error) {
+	var status sql.NullString
+	err := db.QueryRow("select providerStatus from users where id = ?", userID).Scan(&status)
+	if err != nil {
+		return "", errors.New("error getting provider status. " + err.Error())
+	}
+	return ProviderStatus(status.String), nil
+}
+
+// lockProviderStatus reads a provider's status and locks their row until tx ends, so
+// concurrent changes are checked against each other's result
+func lockProviderStatus(userID int64, tx *sql.Tx) (ProviderStatus, error) {
+	var status sql.NullString
+	err := tx.QueryRow("select providerStatus from users where id = ? for update", userID).Scan(&status)
+	if err != nil {
+		return "", errors.New("error getting provider status. " + err.Error())
+	}
+	return ProviderStatus(status.String), nil
+}
+
+// SetProviderStatus moves a provider to a new status and records who made the change
+func SetProviderStatus(userID int64, status ProviderStatus, reason string, actorID int64, e *env.Env) (*ProviderStatusChange, error) {
+	tx, err := e.DB.Begin()
+	if err != nil {
+		return nil, errors.New("error starting transaction. " + err.Error())
+	}
+	defer tx.Rollback()
+	current, err := lockProviderStatus(userID, tx)
+	if err != nil {
+		return nil, err
+	}
+	err = validateStatusChange(current, status, reason)
+	if err != nil {
+		return nil, err
+	}
+	change := ProviderStatusChange{
+		UserID:         userID,
+		Status:         status,
+		PreviousStatus: current,
+		Reason:         reason,
+		ActorID:        actorID,
+		CreatedAt:      time.Now(),
+	}
+
+	// approvedProvider is kept in sync for anything still reading it directly
+	_, err = tx.Exec("update users set providerStatus = ?, approvedProvider = ? where id = ?", status, status == ProviderApproved, userID)
+	if err != nil {
+		return nil, errors.New("error updating provider status. " + err.Error())
+	}
+	result, err := tx.Exec(
+		"insert into provider_status_history (userID, status, previousStatus, reason, actorID, createdAt) values (?, ?, ?, ?, ?, ?)",
+		userID,
+		status,
+		sql.NullString{String: string(current), Valid: current != ""},
+		sql.NullString{String: reason, Valid: reason != ""},
+		sql.NullInt64{Int64: actorID, Valid: actorID != 0},
+		change.CreatedAt,
+	)
+	if err != nil {
+		return nil, errors.New("error inserting provider status history. " + err.Error())
+	}
+	change.ID, err = result.LastInsertId()
+	if err != nil {
+		return nil, errors.New("error getting provider status history id. " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, errors.New("error committing provider status. " + err.Error())
+	}
+	e.Sessions.InvalidateUser(userID)
+	return &change, nil
+}
+
+// ApproveProvider approves a provider, or rejects them if they were never approved and
+// suspends them if they were. Approving a provider who is already approved changes nothing and
+// returns their status without recording a change.
+func ApproveProvider(userID int64, approved bool, reason string, actorID int64, e *env.Env) (*ProviderStatusChange, error) {
+	if approved {
+		change, err := SetProviderStatus(userID, ProviderApproved, reason, actorID, e)
+		if errors.Is(err, ErrProviderStatus) {
+			current, statusErr := getProviderStatus(userID, e.DB)
+			if statusErr == nil && current == ProviderApproved {
+				return &ProviderStatusChange{UserID: userID, Status: current, PreviousStatus: current}, nil
+			}
+		}
+		return change, err
+	}
+	current, err := getProviderStatus(userID, e.DB)
+	if err != nil {
+		return nil, err
+	}
+	if current == ProviderApproved {
+		return SetProviderStatus(userID, ProviderSuspended, reason, actorID, e)
+	}
+	return SetProviderStatus(userID, ProviderRejected, reason, actorID, e)
+}
+
+func GetProviderStatusHistory(userID int64, db *sql.DB) ([]*ProviderStatusChange, error) {
+	rows, err := db.Query("select id, userID, status, previousStatus, reason, actorID, createdAt from provider_status_history where userID = ? order by createdAt, id", userID)
+	if err != nil {
+		return nil, errors.New("error getting provider status history. " + err.Error())
+	}
+	defer rows.Close()
+	history := []*ProviderStatusChange{}
+	for rows.Next() {
+		var change ProviderStatusChange
+		var previousStatus sql.NullString
+		var reason sql.NullString
+		var actorID sql.NullInt64
+		err := rows.Scan(&change.ID, &change.UserID, &change.Status, &previousStatus, &reason, &actorID, &change.CreatedAt)
+		if err != nil {
+			return nil, errors.New("error scanning provider status history. " + err.Error())
+		}
+		change.PreviousStatus = ProviderStatus(previousStatus.String)
+		change.Reason = reason.String
+		change.ActorID = actorID.Int64
+		history = append(history, &change)
+	}
+	return history, nil
+}
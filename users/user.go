@@ -2,26 +2,53 @@ package users
 
 import (
 	"api/env"
+	"api/geo"
 	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/stytchauth/stytch-go/v3/stytch"
 )
 
+// cached sessions expiring within this window are verified with the authenticator again
+const sessionRefreshWindow = 24 * time.Hour
+
+// cached sessions are verified again after this long, so sessions revoked elsewhere stop
+// working within it
+const sessionVerifyInterval = 15 * time.Minute
+
 const TestUser = "sandbox@stytch.com"
 const TestRedirectURL = "http://localhost:8080/localauth"
 const TestToken = "DOYoip3rvIMMW5lgItikFK-Ak1CfMsgjuiCyI7uuU94="
 const TestSessionToken = "WJtR5BCy38Szd5AfoDpf0iqFKEt4EE5JhjlWUY7l3FtY"
 
+// TestTokens returns a magic link token and session token for TestUser. The
+// Stytch sandbox accepts the fixed TestToken and TestSessionToken, while the
+// fake authenticator needs the user to log in first.
+func TestTokens(e *env.Env) (token string, sessionToken string, err error) {
+	if _, ok := e.Auth.(*env.FakeAuth); !ok {
+		return TestToken, TestSessionToken, nil
+	}
+	_, err = Login(UserReq{Email: TestUser, RedirectURL: TestRedirectURL}, e)
+	if err != nil {
+		return "", "", err
+	}
+	token = env.FakeMagicLinkToken(TestUser)
+	sessionToken, err = Authenticate(token, e)
+	if err != nil {
+		return "", "", err
+	}
+	return token, sessionToken, nil
+}
+
 type User struct {
 	ID                int64    `json:"id"`
 	StytchUserID      string   `json:"stytch_user_id"`
 	Email             string   `json:"email"`
 	ActiveRoles       []string `json:"active_roles"`
+	Permissions       []string `json:"permissions"`
 	FirstName         string   `json:"first_name"`
 	LastName          string   `json:"last_name"`
 	Pronouns          string   `json:"pronouns"`
@@ -29,19 +56,59 @@ type User struct {
 	Address           string   `json:"address"`
 	Specialty         string   `json:"specialty"`
 	Phone             string   `json:"phone"`
+	Street            string   `json:"street"`
+	City              string   `json:"city"`
+	State             string   `json:"state"`
+	Zip               string   `json:"zip"`
+	Latitude          *float64 `json:"latitude"`
+	Longitude         *float64 `json:"longitude"`
 	AgreementAccepted bool     `json:"agreement_accepted"`
 	ApprovedProvider  bool     `json:"approved_provider"`
+	ProviderStatus    string   `json:"provider_status"`
 }
 
 type sqlUser struct {
 	User
-	FirstName    sql.NullString
-	LastName     sql.NullString
-	Pronouns     sql.NullString
-	PracticeName sql.NullString
-	Address      sql.NullString
-	Specialty    sql.NullString
-	Phone        sql.NullString
+	FirstName      sql.NullString
+	LastName       sql.NullString
+	Pronouns       sql.NullString
+	PracticeName   sql.NullString
+	Address        sql.NullString
+	Specialty      sql.NullString
+	Phone          sql.NullString
+	Street         sql.NullString
+	City           sql.NullString
+	State          sql.NullString
+	Zip            sql.NullString
+	Latitude       sql.NullFloat64
+	Longitude      sql.NullFloat64
+	ProviderStatus sql.NullString
+}
+
+// userColumns are the users columns scanned by sqlUser.fields
+const userColumns = "id, stytchUserID, email, firstName, lastName, pronouns, practiceName, address, specialty, phone, street, city, state, zip, latitude, longitude, agreementAccepted, providerStatus"
+
+func (u *sqlUser) fields() []interface{} {
+	return []interface{}{
+		&u.ID,
+		&u.StytchUserID,
+		&u.Email,
+		&u.FirstName,
+		&u.LastName,
+		&u.Pronouns,
+		&u.PracticeName,
+		&u.Address,
+		&u.Specialty,
+		&u.Phone,
+		&u.Street,
+		&u.City,
+		&u.State,
+		&u.Zip,
+		&u.Latitude,
+		&u.Longitude,
+		&u.AgreementAccepted,
+		&u.ProviderStatus,
+	}
 }
 
 func (u *sqlUser) ToUser() *User {
@@ -56,13 +123,30 @@ func (u *sqlUser) ToUser() *User {
 	user.Address = u.Address.String
 	user.Specialty = u.Specialty.String
 	user.Phone = u.Phone.String
+	user.Street = u.Street.String
+	user.City = u.City.String
+	user.State = u.State.String
+	user.Zip = u.Zip.String
+	if u.Latitude.Valid && u.Longitude.Valid {
+		user.Latitude = &u.Latitude.Float64
+		user.Longitude = &u.Longitude.Float64
+	}
 	user.AgreementAccepted = u.AgreementAccepted
-	user.ApprovedProvider = u.ApprovedProvider
+	user.ProviderStatus = u.ProviderStatus.String
+	user.ApprovedProvider = user.ProviderStatus == string(ProviderApproved)
+	return &user
+}
+
+// copy returns a user that can be changed without affecting the session cache
+func (u *User) copy() *User {
+	user := *u
+	user.ActiveRoles = append([]string{}, u.ActiveRoles...)
+	user.Permissions = append([]string{}, u.Permissions...)
 	return &user
 }
 
 func GetUsers(db *sql.DB) ([]*User, error) {
-	selectUsers := "select id, stytchUserID, email, firstName, lastName, pronouns, practiceName, address, specialty, phone, agreementAccepted, approvedProvider from users"
+	selectUsers := "select " + userColumns + " from users"
 	rows, err := db.Query(selectUsers)
 	if err != nil {
 		return nil, err
@@ -71,20 +155,7 @@ func GetUsers(db *sql.DB) ([]*User, error) {
 	var users []*User
 	for rows.Next() {
 		var dbUser sqlUser
-		err := rows.Scan(
-			&dbUser.ID,
-			&dbUser.StytchUserID,
-			&dbUser.Email,
-			&dbUser.FirstName,
-			&dbUser.LastName,
-			&dbUser.Pronouns,
-			&dbUser.PracticeName,
-			&dbUser.Address,
-			&dbUser.Specialty,
-			&dbUser.Phone,
-			&dbUser.AgreementAccepted,
-			&dbUser.ApprovedProvider,
-		)
+		err := rows.Scan(dbUser.fields()...)
 		if err != nil {
 			return nil, err
 		}
@@ -110,22 +181,9 @@ func GetUsers(db *sql.DB) ([]*User, error) {
 
 // retrieves a single user from the database
 func Get(id int64, db *sql.DB) (*User, error) {
-	row := db.QueryRow("SELECT id, stytchUserID, email, firstName, lastName, pronouns, practiceName, address, specialty, phone, agreementAccepted, approvedProvider FROM users WHERE id = ?", id)
+	row := db.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id)
 	var dbUser sqlUser
-	err := row.Scan(
-		&dbUser.ID,
-		&dbUser.StytchUserID,
-		&dbUser.Email,
-		&dbUser.FirstName,
-		&dbUser.LastName,
-		&dbUser.Pronouns,
-		&dbUser.PracticeName,
-		&dbUser.Address,
-		&dbUser.Specialty,
-		&dbUser.Phone,
-		&dbUser.AgreementAccepted,
-		&dbUser.ApprovedProvider,
-	)
+	err := row.Scan(dbUser.fields()...)
 	if err != nil {
 		return nil, err
 	}
@@ -137,22 +195,9 @@ func GetUserByStytchID(stytchUserID *string, e *env.Env) (*User, error) {
 	if stytchUserID == nil {
 		return nil, errors.New("stytchUserID is required")
 	}
-	row := e.DB.QueryRow("SELECT id, stytchUserID, email, firstName, lastName, pronouns, practiceName, address, specialty, phone, agreementAccepted, approvedProvider FROM users WHERE stytchUserID = ?", *stytchUserID)
+	row := e.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE stytchUserID = ?", *stytchUserID)
 	var dbUser sqlUser
-	err := row.Scan(
-		&dbUser.ID,
-		&dbUser.StytchUserID,
-		&dbUser.Email,
-		&dbUser.FirstName,
-		&dbUser.LastName,
-		&dbUser.Pronouns,
-		&dbUser.PracticeName,
-		&dbUser.Address,
-		&dbUser.Specialty,
-		&dbUser.Phone,
-		&dbUser.AgreementAccepted,
-		&dbUser.ApprovedProvider,
-	)
+	err := row.Scan(dbUser.fields()...)
 	if err != nil {
 		return nil, err
 	}
@@ -171,6 +216,10 @@ func GetUserByStytchID(stytchUserID *string, e *env.Env) (*User, error) {
 		}
 		user.ActiveRoles = append(user.ActiveRoles, role)
 	}
+	user.Permissions, err = getUserPermissions(user.ID, e.DB)
+	if err != nil {
+		return nil, err
+	}
 	return user, nil
 }
 
@@ -178,19 +227,31 @@ func GetUserBySession(sessionToken string, e *env.Env) (*User, error) {
 	if sessionToken == "" {
 		return nil, errors.New("session token is required")
 	}
-	// get user id from session token
-	params := &stytch.SessionsAuthenticateParams{
-		SessionToken:           sessionToken,
-		SessionDurationMinutes: 10080,
+	// only verify cached sessions again when they are close to expiring or were verified a while ago
+	cached, stale, ok := e.Sessions.Get(sessionToken)
+	if ok && time.Until(cached.Session.ExpiresAt) > sessionRefreshWindow && time.Since(cached.VerifiedAt) < sessionVerifyInterval {
+		if user, isUser := cached.User.(*User); isUser && !stale {
+			return user.copy(), nil
+		}
+		user, err := GetUserByStytchID(&cached.Session.UserID, e)
+		if err != nil {
+			return nil, errors.New("failed to get user from DB: " + err.Error())
+		}
+		e.Sessions.Set(sessionToken, env.CachedSession{Session: cached.Session, UserID: user.ID, User: user.copy(), VerifiedAt: cached.VerifiedAt})
+		return user, nil
 	}
-	resp, err := e.Stytch.Sessions.Authenticate(params)
+
+	// get user id from session token
+	session, err := e.Auth.AuthenticateSession(sessionToken, sessionDurationMinutes)
 	if err != nil {
+		e.Sessions.Delete(sessionToken)
 		return nil, errors.New("failed to authenticate session: " + err.Error())
 	}
-	user, err := GetUserByStytchID(&resp.Session.UserID, e)
+	user, err := GetUserByStytchID(&session.UserID, e)
 	if err != nil {
 		return nil, errors.New("failed to get user from DB: " + err.Error())
 	}
+	e.Sessions.Set(sessionToken, env.CachedSession{Session: *session, UserID: user.ID, User: user.copy(), VerifiedAt: time.Now()})
 	return user, nil
 }
 
@@ -228,8 +289,20 @@ func UpdateUser(sessionToken string, user *User, e *env.Env) (int64, error) {
 		user.Email = existingUser.Email
 	}
 
+	// coordinates come from the zip code so providers can be found by distance
+	var latitude sql.NullFloat64
+	var longitude sql.NullFloat64
+	user.Latitude = nil
+	user.Longitude = nil
+	if point, ok := geo.LookupZip(user.Zip); ok {
+		latitude = sql.NullFloat64{Float64: point.Lat, Valid: true}
+		longitude = sql.NullFloat64{Float64: point.Lng, Valid: true}
+		user.Latitude = &point.Lat
+		user.Longitude = &point.Lng
+	}
+
 	_, err = e.DB.Exec(
-		"UPDATE users SET email = ?, firstName = ?, lastName = ?, pronouns = ?, practiceName = ?, address = ?, specialty = ?, phone = ? WHERE stytchUserID = ?",
+		"UPDATE users SET email = ?, firstName = ?, lastName = ?, pronouns = ?, practiceName = ?, address = ?, specialty = ?, phone = ?, street = ?, city = ?, state = ?, zip = ?, latitude = ?, longitude = ? WHERE stytchUserID = ?",
 		user.Email,
 		user.FirstName,
 		user.LastName,
@@ -238,11 +311,18 @@ func UpdateUser(sessionToken string, user *User, e *env.Env) (int64, error) {
 		user.Address,
 		user.Specialty,
 		user.Phone,
+		user.Street,
+		user.City,
+		user.State,
+		user.Zip,
+		latitude,
+		longitude,
 		user.StytchUserID,
 	)
 	if err != nil {
 		return 0, errors.New("failed to update user: " + err.Error())
 	}
+	e.Sessions.InvalidateUser(existingUser.ID)
 
 	return existingUser.ID, nil
 }
@@ -270,18 +350,25 @@ func DeleteUser(stytchUserID *string, e *env.Env) error {
 	if stytchUserID == nil {
 		return errors.New("stytchUserID is required")
 	}
-	_, err := e.Stytch.Users.Delete(*stytchUserID)
+	err := e.Auth.DeleteUser(*stytchUserID)
 	if err != nil {
-		if strings.Contains(err.Error(), "status code: 404") {
+		if err == env.ErrAuthUserNotFound {
 			fmt.Println("Stytch user not found")
 		} else {
 			return errors.New("failed to delete user from Stytch: " + err.Error())
 		}
 	}
+	user, err := GetUserByStytchID(stytchUserID, e)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New("failed to get user from DB: " + err.Error())
+	}
 	_, err = e.SqlExecute(fmt.Sprintf("DELETE FROM users WHERE stytchUserID = '%s'", *stytchUserID))
 	if err != nil {
 		return errors.New("failed to delete user from DB: " + err.Error())
 	}
+	if user != nil {
+		e.Sessions.InvalidateUser(user.ID)
+	}
 	return nil
 }
 
@@ -296,5 +383,6 @@ func UpdateAgreement(id *int64, accepted *bool, e *env.Env) error {
 	if err != nil {
 		return errors.New("failed to update user agreement in DB: " + err.Error())
 	}
+	e.Sessions.InvalidateUser(*id)
 	return nil
 }
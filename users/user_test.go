@@ -48,7 +48,11 @@ func TestUpdateUser(t *testing.T) {
 	if err != nil {
 		t.Error("Login failed. " + err.Error())
 	}
-	sessToken, err := users.Authenticate(users.TestToken, e)
+	token, _, err := users.TestTokens(e)
+	if err != nil {
+		t.Error("Failed to get test token. " + err.Error())
+	}
+	sessToken, err := users.Authenticate(token, e)
 	if err != nil {
 		t.Error("Failed to authenticate user. " + err.Error())
 	}
@@ -116,7 +120,11 @@ func TestGetUserBySession(t *testing.T) {
 	if err != nil {
 		t.Error("Login failed. " + err.Error())
 	}
-	sessToken, err := users.Authenticate(users.TestToken, e)
+	token, _, err := users.TestTokens(e)
+	if err != nil {
+		t.Error("Failed to get test token. " + err.Error())
+	}
+	sessToken, err := users.Authenticate(token, e)
 	if err != nil {
 		t.Error("Failed to authenticate user. " + err.Error())
 	}